@@ -0,0 +1,148 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"context"
+)
+
+// AliasIndex implements substring searches over results which each have many searchable aliases
+type AliasIndex struct {
+	Index   *InvertedSuffix // Index is over all aliases. Its Results are the aliases, and its Values are their result IDs
+	Results []string        // Results[ID] is the string value of the result with that ID. Used as a return value
+	Values  []interface{}   // Values[ID] is some data mapped to the result with that ID
+}
+
+// NewAliased creates an alias index from a list of aliases for each result, mapping data, and a string->[]byte converter
+// The ID of a result is its position in Results
+func NewAliased(Aliases [][]string, Results []string, Data []interface{}, Converter func(string) []byte) *AliasIndex {
+	Words := make([]string, 0, len(Aliases))
+	IDs := make([]interface{}, 0, len(Aliases))
+	for ID, Keys := range Aliases {
+		for _, Key := range Keys {
+			Words = append(Words, Key)
+			IDs = append(IDs, ID)
+		}
+	}
+	return &AliasIndex{New(Words, Words, IDs, Converter), Results, Data}
+}
+
+// Insert adds a result with the given aliases, returning its ID
+func (AI *AliasIndex) Insert(Aliases []string, Result string, Data interface{}) int {
+	ID := len(AI.Results)
+	AI.Results = append(AI.Results, Result)
+	AI.Values = append(AI.Values, Data)
+	for _, Alias := range Aliases {
+		AI.AddAlias(ID, Alias)
+	}
+	return ID
+}
+
+// AddAlias adds an alias to the result with the given ID
+func (AI *AliasIndex) AddAlias(ID int, Alias string) {
+	IS := AI.Index
+	IS.add(IS.Converter(Alias), Alias, ID)
+}
+
+// Query returns the results with an alias containing the query, their stored values,
+// and which alias matched, unsorted
+// Input:
+//     Word: The substring to search for.
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+func (AI *AliasIndex) Query(Word string, ResultsLimit int) ([]string, []interface{}, []string) {
+	Results, Values, Aliases, _ := AI.QueryContext(context.Background(), Word, ResultsLimit)
+	return Results, Values, Aliases
+}
+
+// QueryContext is like Query, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (AI *AliasIndex) QueryContext(ctx context.Context, Word string, ResultsLimit int) ([]string, []interface{}, []string, error) {
+	IS := AI.Index
+	Query := IS.Converter(Word)
+	if ResultsLimit == 0 {
+		return []string{}, []interface{}{}, []string{}, nil
+	}
+	if ResultsLimit < 0 {
+		ResultsLimit = 0
+	}
+	Results := make([]string, 0, ResultsLimit)
+	Values := make([]interface{}, 0, ResultsLimit)
+	Aliases := make([]string, 0, ResultsLimit)
+	G := newQueryGuard(ctx)
+	low, high := IS.Search(Query)
+	a := 0
+	used := make(map[int]bool, 0)
+	for k := low; k < high; k++ {
+		if !G.step(1) {
+			return Results, Values, Aliases, G.err
+		}
+		x := IS.WordIndex[k]
+		ID := IS.Values[x].(int)
+		if _, ok := used[ID]; ok {
+			continue
+		}
+		used[ID] = true
+		Results = append(Results, AI.Results[ID])
+		Values = append(Values, AI.Values[ID])
+		Aliases = append(Aliases, IS.Results[x])
+		a++
+		if a == ResultsLimit {
+			return Results, Values, Aliases, nil
+		}
+	}
+	return Results, Values, Aliases, nil
+}
+
+// SortedQuery returns the results with an alias containing the query sorted,
+// and which alias matched. Each result is scored by its best scoring alias
+// Input:
+//     Word: The substring to search for.
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Sorter: Takes (Result, Value, Length (of the alias), Index (where Query begins in the alias))
+//         (string, interface{}, int, int) and produces a value (float64) to sort by (largest first).
+func (AI *AliasIndex) SortedQuery(Word string, ResultsLimit int, Sorter func(string, interface{}, int, int) float64) ([]string, []interface{}, []float64, []string) {
+	Results, Values, Scores, Aliases, _ := AI.SortedQueryContext(context.Background(), Word, ResultsLimit, Sorter)
+	return Results, Values, Scores, Aliases
+}
+
+// SortedQueryContext is like SortedQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (AI *AliasIndex) SortedQueryContext(ctx context.Context, Word string, ResultsLimit int, Sorter func(string, interface{}, int, int) float64) ([]string, []interface{}, []float64, []string, error) {
+	IS := AI.Index
+	Query := IS.Converter(Word)
+	G := newQueryGuard(ctx)
+	low, high := IS.Search(Query)
+	Top := newTopResults(ResultsLimit)
+	for k := low; k < high; k++ {
+		if !G.step(1) {
+			break
+		}
+		x := IS.WordIndex[k]
+		ID := IS.Values[x].(int)
+		w := AI.Results[ID]
+		v := AI.Values[ID]
+		Top.add(ID, x, w, v, Sorter(w, v, len(IS.Words[x]), IS.SuffixIndex[k]))
+	}
+	Results, Values, Scores := Top.split()
+	Aliases := make([]string, len(Results))
+	for i, r := range Top.results() {
+		Aliases[i] = IS.Results[r.Aux]
+	}
+	return Results, Values, Scores, Aliases, G.err
+}
@@ -0,0 +1,185 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"bytes"
+	"sort"
+	"unicode/utf8"
+)
+
+// commonPrefix returns the length of the common prefix of a and b, stopping at a FormSeparator
+// (substrings don't span the forms of a word) and, for RuneAligned indexes, at the last whole rune
+func (IS *InvertedSuffix) commonPrefix(a, b []byte) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] && a[n] != FormSeparator {
+		n++
+	}
+	if IS.RuneAligned {
+		for n > 0 && n < len(a) && !utf8.RuneStart(a[n]) {
+			n--
+		}
+	}
+	return n
+}
+
+// suffix returns the suffix at k of the sorted suffixes
+func (IS *InvertedSuffix) suffix(k int) []byte {
+	return IS.Words[IS.WordIndex[k]][IS.SuffixIndex[k]:]
+}
+
+// containing returns the index of each word containing the converted query, in increasing order
+func (IS *InvertedSuffix) containing(Query []byte) []int {
+	low, high := IS.Search(Query)
+	Words := make([]int, 0)
+	IS.distinctWords(nil, low, high, func(x int) { Words = append(Words, x) })
+	sort.Ints(Words)
+	return Words
+}
+
+// LongestRepeatedSubstring returns the longest (converted) substring contained in at least two words,
+// and the index of each word containing it. Returns an empty substring and no words if no two words share a character.
+// The longest common prefix of two suffixes of different words is found at some adjacent pair of sorted suffixes
+func (IS *InvertedSuffix) LongestRepeatedSubstring() ([]byte, []int) {
+	Best := []byte{}
+	for k := 1; k < len(IS.WordIndex); k++ {
+		if IS.WordIndex[k] == IS.WordIndex[k-1] {
+			continue
+		}
+		a := IS.suffix(k - 1)
+		if n := IS.commonPrefix(a, IS.suffix(k)); n > len(Best) {
+			Best = a[:n]
+		}
+	}
+	if len(Best) == 0 {
+		return []byte{}, []int{}
+	}
+	Substring := append([]byte{}, Best...)
+	return Substring, IS.containing(Substring)
+}
+
+// prefixUnits returns the length in bytes of the first n characters (bytes, or runes for RuneAligned indexes)
+// of Suffix, or -1 if Suffix is shorter or they include a FormSeparator
+func (IS *InvertedSuffix) prefixUnits(Suffix []byte, n int) int {
+	j := 0
+	for u := 0; u < n; u++ {
+		if j >= len(Suffix) || Suffix[j] == FormSeparator {
+			return -1
+		}
+		if IS.RuneAligned {
+			_, Size := utf8.DecodeRune(Suffix[j:])
+			j += Size
+		} else {
+			j++
+		}
+	}
+	return j
+}
+
+// MostFrequentSubstrings returns up to n of the (converted) substrings of length k (in bytes, or runes for RuneAligned indexes)
+// contained in the most words, and the number of words containing each, most first (then in sorted order)
+// Input:
+//     k: The length of the substrings
+//     n: The maximum number of substrings to return. Set to -1 for no limit
+func (IS *InvertedSuffix) MostFrequentSubstrings(k, n int) ([][]byte, []int) {
+	Substrings := make([][]byte, 0)
+	Counts := make([]int, 0)
+	// Suffixes with the same first k characters are adjacent, so each group is counted as it's scanned
+	Group := make(map[int]bool, 0)
+	var Current []byte
+	for i := 0; i <= len(IS.WordIndex); i++ {
+		var Prefix []byte
+		if i < len(IS.WordIndex) {
+			Suffix := IS.suffix(i)
+			if j := IS.prefixUnits(Suffix, k); j >= 0 {
+				Prefix = Suffix[:j]
+			}
+		}
+		if Current != nil && (Prefix == nil || !bytes.Equal(Prefix, Current)) {
+			Substrings = append(Substrings, append([]byte{}, Current...))
+			Counts = append(Counts, len(Group))
+			Current = nil
+		}
+		if Prefix == nil {
+			continue
+		}
+		if Current == nil {
+			Current = Prefix
+			Group = make(map[int]bool, 0)
+		}
+		Group[IS.WordIndex[i]] = true
+	}
+	Order := make([]int, len(Substrings))
+	for i := range Order {
+		Order[i] = i
+	}
+	// Substrings are already in sorted order, so a stable sort breaks ties by substring
+	sort.SliceStable(Order, func(i, j int) bool { return Counts[Order[i]] > Counts[Order[j]] })
+	if n >= 0 && len(Order) > n {
+		Order = Order[:n]
+	}
+	TopSubstrings := make([][]byte, len(Order))
+	TopCounts := make([]int, len(Order))
+	for i, o := range Order {
+		TopSubstrings[i] = Substrings[o]
+		TopCounts[i] = Counts[o]
+	}
+	return TopSubstrings, TopCounts
+}
+
+// LongestCommonSubstring returns the longest (converted) substring of the query contained in any word,
+// and the index of each word containing it. Returns an empty substring and no words if no character of the query matches
+// Each start in the query narrows the suffix range one byte at a time, as far as it goes.
+// A substring of a match also matches, so each start resumes from the end of the previous match
+func (IS *InvertedSuffix) LongestCommonSubstring(Word string) ([]byte, []int) {
+	Query := IS.Converter(Word)
+	Best := []byte{}
+	End := 0
+	for i := 0; i < len(Query); i++ {
+		if IS.RuneAligned && !utf8.RuneStart(Query[i]) {
+			continue
+		}
+		if End < i {
+			End = i
+		}
+		low, high := IS.Search(Query[i:End])
+		for End < len(Query) {
+			l, h := IS.Narrow(low, high, Query[i:End+1], End-i)
+			if l == h {
+				break
+			}
+			low, high = l, h
+			End++
+		}
+		Match := Query[i:End]
+		if IS.RuneAligned {
+			for len(Match) > 0 && i+len(Match) < len(Query) && !utf8.RuneStart(Query[i+len(Match)]) {
+				Match = Match[:len(Match)-1]
+			}
+		}
+		if len(Match) > len(Best) {
+			Best = Match
+		}
+	}
+	if len(Best) == 0 {
+		return []byte{}, []int{}
+	}
+	Substring := append([]byte{}, Best...)
+	return Substring, IS.containing(Substring)
+}
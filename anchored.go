@@ -0,0 +1,204 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"context"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QueryMode controls where in a word a query may match
+// Word boundaries include the FormSeparator of words indexed by NewTransliterated
+type QueryMode int
+
+const (
+	SubstringMode   QueryMode = iota // SubstringMode matches anywhere in the word, like Query
+	PrefixMode                       // PrefixMode matches at the start of the word
+	SuffixMode                       // SuffixMode matches at the end of the word
+	ExactMode                        // ExactMode matches the whole word
+	TokenMode                        // TokenMode matches whole tokens, bounded by whitespace, punctuation or the word boundaries
+	TokenPrefixMode                  // TokenPrefixMode matches at the start of a token
+)
+
+// exactEnd returns the end of the suffixes in [low, high) with length n
+// Shorter suffixes sort first, so these are all at the start of the range of their query
+func (IS *InvertedSuffix) exactEnd(low, high, n int) int {
+	return low + sort.Search(high-low, func(i int) bool {
+		k := low + i
+		return len(IS.Words[IS.WordIndex[k]])-IS.SuffixIndex[k] > n
+	})
+}
+
+// AnchoredSearch performs an exact substring search for the query in the word dictionary
+// Returns the boundaries (low/high) of sorted suffixes which have the query as a prefix,
+// narrowed to the suffixes which end with the query for SuffixMode and ExactMode.
+// Not every suffix in the range matches the mode: check with AnchoredMatch.
+// Words with several forms (see NewTransliterated) may also match at the end of a form: see AnchoredRanges
+// This is a low-level interface. I wouldn't recommend using this yourself
+func (IS *InvertedSuffix) AnchoredSearch(Query []byte, Mode QueryMode) (int, int) {
+	low, high := IS.Search(Query)
+	if Mode == SuffixMode || Mode == ExactMode {
+		high = IS.exactEnd(low, high, len(Query))
+	}
+	return low, high
+}
+
+// AnchoredRanges returns the ranges of sorted suffixes which may match the query in the given mode
+// Like AnchoredSearch, but also includes the suffixes ending with the query at a FormSeparator
+// This is a low-level interface. I wouldn't recommend using this yourself
+func (IS *InvertedSuffix) AnchoredRanges(Query []byte, Mode QueryMode) [][2]int {
+	low, high := IS.AnchoredSearch(Query, Mode)
+	Ranges := [][2]int{{low, high}}
+	if Mode == SuffixMode || Mode == ExactMode {
+		Form := make([]byte, len(Query)+1)
+		copy(Form, Query)
+		Form[len(Query)] = FormSeparator
+		low, high := IS.Search(Form)
+		if low < high {
+			Ranges = append(Ranges, [2]int{low, high})
+		}
+	}
+	return Ranges
+}
+
+// isBoundary returns whether r separates tokens
+func isBoundary(r rune) bool {
+	return r == FormSeparator || unicode.IsSpace(r) || unicode.IsPunct(r)
+}
+
+// startsToken returns whether Word[j:] starts at a token boundary
+func startsToken(Word []byte, j int) bool {
+	if j == 0 {
+		return true
+	}
+	r, _ := utf8.DecodeLastRune(Word[:j])
+	return isBoundary(r)
+}
+
+// endsToken returns whether Word[:j] ends at a token boundary
+func endsToken(Word []byte, j int) bool {
+	if j == len(Word) {
+		return true
+	}
+	r, _ := utf8.DecodeRune(Word[j:])
+	return isBoundary(r)
+}
+
+// AnchoredMatch returns whether the suffix at k of a range returned by
+// AnchoredRanges for a query of length n matches in the given mode
+// This is a low-level interface. I wouldn't recommend using this yourself
+func (IS *InvertedSuffix) AnchoredMatch(k, n int, Mode QueryMode) bool {
+	Word := IS.Words[IS.WordIndex[k]]
+	j := IS.SuffixIndex[k]
+	switch Mode {
+	case PrefixMode, ExactMode:
+		return j == 0 || Word[j-1] == FormSeparator
+	case TokenMode:
+		return startsToken(Word, j) && endsToken(Word, j+n)
+	case TokenPrefixMode:
+		return startsToken(Word, j)
+	}
+	return true
+}
+
+// anchoredScan calls f with each suffix (in sorted order) matching the query in the given mode,
+// until f returns false or G stops the scan
+func (IS *InvertedSuffix) anchoredScan(G *queryGuard, Query []byte, Mode QueryMode, f func(k int) bool) {
+	n := len(Query)
+	for _, Range := range IS.AnchoredRanges(Query, Mode) {
+		for k := Range[0]; k < Range[1]; k++ {
+			if !G.step(1) {
+				return
+			}
+			if IS.AnchoredMatch(k, n, Mode) && !f(k) {
+				return
+			}
+		}
+	}
+}
+
+// AnchoredQuery returns the strings which contain the query in the given mode, and their stored values unsorted
+// Input:
+//     Word: The substring to search for.
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Mode: Where in the word the query may match, e.g. PrefixMode
+func (IS *InvertedSuffix) AnchoredQuery(Word string, ResultsLimit int, Mode QueryMode) ([]string, []interface{}) {
+	Results, Values, _ := IS.AnchoredQueryContext(context.Background(), Word, ResultsLimit, Mode)
+	return Results, Values
+}
+
+// AnchoredQueryContext is like AnchoredQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) AnchoredQueryContext(ctx context.Context, Word string, ResultsLimit int, Mode QueryMode) ([]string, []interface{}, error) {
+	Query := IS.Converter(Word)
+	if ResultsLimit == 0 {
+		return []string{}, []interface{}{}, nil
+	}
+	if ResultsLimit < 0 {
+		ResultsLimit = 0
+	}
+	Results := make([]string, 0, ResultsLimit)
+	Values := make([]interface{}, 0, ResultsLimit)
+	G := newQueryGuard(ctx)
+	a := 0
+	used := make(map[int]bool, 0)
+	IS.anchoredScan(G, Query, Mode, func(k int) bool {
+		x := IS.WordIndex[k]
+		if _, ok := used[x]; ok {
+			return true
+		}
+		used[x] = true
+		Results = append(Results, IS.Results[x])
+		Values = append(Values, IS.Values[x])
+		a++
+		return a != ResultsLimit
+	})
+	return Results, Values, G.err
+}
+
+// SortedAnchoredQuery returns the strings which contain the query in the given mode sorted
+// Input:
+//     Word: The substring to search for.
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Mode: Where in the word the query may match, e.g. PrefixMode
+//     Sorter: Takes (Result, Value, Length, Index (where Query begins in Result))
+//         (string, interface{}, int, int) and produces a value (float64) to sort by (largest first).
+func (IS *InvertedSuffix) SortedAnchoredQuery(Word string, ResultsLimit int, Mode QueryMode, Sorter func(string, interface{}, int, int) float64) ([]string, []interface{}, []float64) {
+	Results, Values, Scores, _ := IS.SortedAnchoredQueryContext(context.Background(), Word, ResultsLimit, Mode, Sorter)
+	return Results, Values, Scores
+}
+
+// SortedAnchoredQueryContext is like SortedAnchoredQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) SortedAnchoredQueryContext(ctx context.Context, Word string, ResultsLimit int, Mode QueryMode, Sorter func(string, interface{}, int, int) float64) ([]string, []interface{}, []float64, error) {
+	Query := IS.Converter(Word)
+	G := newQueryGuard(ctx)
+	Top := newTopResults(ResultsLimit)
+	IS.anchoredScan(G, Query, Mode, func(k int) bool {
+		x := IS.WordIndex[k]
+		w := IS.Results[x]
+		v := IS.Values[x]
+		Top.add(x, 0, w, v, Sorter(w, v, len(IS.Words[x]), IS.SuffixIndex[k]))
+		return true
+	})
+	Results, Values, Scores := Top.split()
+	return Results, Values, Scores, G.err
+}
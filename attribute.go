@@ -0,0 +1,184 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"context"
+)
+
+// Attribute is a categorical attribute of the words (e.g. region or explicit),
+// indexed as a bitset of word indexes for each attribute value
+type Attribute struct {
+	Key    func(interface{}) string // Key produces the attribute value of a word from its stored value
+	Of     []string                 // Of[x] is the attribute value of word x
+	values map[string]bitset
+}
+
+// AttributeFilter restricts a query to the words whose attribute is any of Values
+type AttributeFilter struct {
+	Attribute string   // Attribute is the name of the attribute, as given to AddAttribute
+	Values    []string // Values are the allowed attribute values
+}
+
+// AddAttribute indexes a categorical attribute of the words, for use with AttributeQuery
+// Call it after New. The attribute is kept up to date by Insert
+// Input:
+//     Name: The name of the attribute, used by AttributeFilter
+//     Key: Takes the Value of a word (interface{}) and produces its attribute value (string)
+func (IS *InvertedSuffix) AddAttribute(Name string, Key func(interface{}) string) {
+	if IS.Attributes == nil {
+		IS.Attributes = make(map[string]*Attribute)
+	}
+	A := &Attribute{Key, make([]string, len(IS.Words)), make(map[string]bitset)}
+	for x, v := range IS.Values {
+		a := Key(v)
+		A.Of[x] = a
+		Set := A.values[a]
+		if Set == nil {
+			Set = newBitset(len(IS.Words))
+		}
+		Set.set(x)
+		A.values[a] = Set
+	}
+	IS.Attributes[Name] = A
+}
+
+// updateAttributes reindexes the attributes of word x after its value is set
+func (IS *InvertedSuffix) updateAttributes(x int) {
+	for _, A := range IS.Attributes {
+		a := A.Key(IS.Values[x])
+		if x < len(A.Of) {
+			A.values[A.Of[x]].clear(x)
+			A.Of[x] = a
+		} else {
+			A.Of = append(A.Of, a)
+		}
+		Set := A.values[a]
+		Set.set(x)
+		A.values[a] = Set
+	}
+}
+
+// allowed returns the bitset of words passing all of the filters (nil if there are none)
+// Values of each filter are combined with OR, and filters with AND.
+// A filter on an unknown attribute matches no words
+func (IS *InvertedSuffix) allowed(Filters []AttributeFilter) bitset {
+	var Allowed bitset
+	for i, Filter := range Filters {
+		Set := bitset{}
+		if A, ok := IS.Attributes[Filter.Attribute]; ok {
+			for _, a := range Filter.Values {
+				Set = Set.union(A.values[a])
+			}
+		}
+		if i == 0 {
+			Allowed = Set
+		} else {
+			Allowed = Allowed.intersect(Set)
+		}
+	}
+	return Allowed
+}
+
+// AttributeQuery returns the strings which contain the query and pass all of the attribute filters,
+// and their stored values unsorted. The limit applies after filtering
+// Input:
+//     Word: The substring to search for.
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Filters: The attribute filters, e.g. AttributeFilter{"region", []string{"US", "CA"}}
+func (IS *InvertedSuffix) AttributeQuery(Word string, ResultsLimit int, Filters ...AttributeFilter) ([]string, []interface{}) {
+	Results, Values, _ := IS.AttributeQueryContext(context.Background(), Word, ResultsLimit, Filters...)
+	return Results, Values
+}
+
+// AttributeQueryContext is like AttributeQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) AttributeQueryContext(ctx context.Context, Word string, ResultsLimit int, Filters ...AttributeFilter) ([]string, []interface{}, error) {
+	Query := IS.Converter(Word)
+	if ResultsLimit == 0 {
+		return []string{}, []interface{}{}, nil
+	}
+	if ResultsLimit < 0 {
+		ResultsLimit = 0
+	}
+	Results := make([]string, 0, ResultsLimit)
+	Values := make([]interface{}, 0, ResultsLimit)
+	G := newQueryGuard(ctx)
+	Allowed := IS.allowed(Filters)
+	low, high := IS.Search(Query)
+	a := 0
+	used := make(map[int]bool, 0)
+	for k := low; k < high; k++ {
+		if !G.step(1) {
+			return Results, Values, G.err
+		}
+		x := IS.WordIndex[k]
+		if len(Filters) > 0 && !Allowed.has(x) {
+			continue
+		}
+		if _, ok := used[x]; ok {
+			continue
+		}
+		used[x] = true
+		Results = append(Results, IS.Results[x])
+		Values = append(Values, IS.Values[x])
+		a++
+		if a == ResultsLimit {
+			return Results, Values, nil
+		}
+	}
+	return Results, Values, nil
+}
+
+// SortedAttributeQuery returns the strings which contain the query and pass all of the attribute filters sorted
+// The limit applies after filtering, and filtered out words are never scored
+// Input:
+//     Word: The substring to search for.
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Sorter: Takes (Result, Value, Length, Index (where Query begins in Result))
+//         (string, interface{}, int, int) and produces a value (float64) to sort by (largest first).
+//     Filters: The attribute filters, e.g. AttributeFilter{"region", []string{"US", "CA"}}
+func (IS *InvertedSuffix) SortedAttributeQuery(Word string, ResultsLimit int, Sorter func(string, interface{}, int, int) float64, Filters ...AttributeFilter) ([]string, []interface{}, []float64) {
+	Results, Values, Scores, _ := IS.SortedAttributeQueryContext(context.Background(), Word, ResultsLimit, Sorter, Filters...)
+	return Results, Values, Scores
+}
+
+// SortedAttributeQueryContext is like SortedAttributeQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) SortedAttributeQueryContext(ctx context.Context, Word string, ResultsLimit int, Sorter func(string, interface{}, int, int) float64, Filters ...AttributeFilter) ([]string, []interface{}, []float64, error) {
+	Query := IS.Converter(Word)
+	G := newQueryGuard(ctx)
+	Allowed := IS.allowed(Filters)
+	low, high := IS.Search(Query)
+	Top := newTopResults(ResultsLimit)
+	for k := low; k < high; k++ {
+		if !G.step(1) {
+			break
+		}
+		x := IS.WordIndex[k]
+		if len(Filters) > 0 && !Allowed.has(x) {
+			continue
+		}
+		w := IS.Results[x]
+		v := IS.Values[x]
+		Top.add(x, 0, w, v, Sorter(w, v, len(IS.Words[x]), IS.SuffixIndex[k]))
+	}
+	Results, Values, Scores := Top.split()
+	return Results, Values, Scores, G.err
+}
@@ -0,0 +1,70 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+// bitset is a set of word indexes
+type bitset []uint64
+
+// newBitset creates an empty bitset with room for word indexes below n
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)>>6)
+}
+
+func (B bitset) has(i int) bool {
+	return i>>6 < len(B) && B[i>>6]&(1<<uint(i&63)) != 0
+}
+
+// set adds i to the bitset, growing it if needed
+func (B *bitset) set(i int) {
+	for i>>6 >= len(*B) {
+		*B = append(*B, 0)
+	}
+	(*B)[i>>6] |= 1 << uint(i&63)
+}
+
+func (B bitset) clear(i int) {
+	if i>>6 < len(B) {
+		B[i>>6] &^= 1 << uint(i&63)
+	}
+}
+
+// union returns the union of two bitsets
+func (B bitset) union(C bitset) bitset {
+	if len(B) < len(C) {
+		B, C = C, B
+	}
+	Union := make(bitset, len(B))
+	copy(Union, B)
+	for i, c := range C {
+		Union[i] |= c
+	}
+	return Union
+}
+
+// intersect returns the intersection of two bitsets
+func (B bitset) intersect(C bitset) bitset {
+	if len(B) > len(C) {
+		B, C = C, B
+	}
+	Intersection := make(bitset, len(B))
+	for i, b := range B {
+		Intersection[i] = b & C[i]
+	}
+	return Intersection
+}
@@ -0,0 +1,455 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QuerySyntax defines the operators of the boolean query language
+// Terms separated by whitespace (or the AndWord) must all match
+type QuerySyntax struct {
+	Or      rune   // Or separates alternatives, e.g. "rock | metal"
+	Not     rune   // Not, at the start of a term or group, excludes its matches, e.g. "-live"
+	Open    rune   // Open starts a group, e.g. "(rock | metal)"
+	Close   rune   // Close ends a group
+	Quote   rune   // Quote surrounds a literal, which may contain whitespace and operators, e.g. "\"let it be\""
+	OrWord  string // OrWord is a term which acts as Or, e.g. "OR". Leave empty to disable
+	AndWord string // AndWord is a term which is ignored between two terms, e.g. "AND". Leave empty to disable
+	NotWord string // NotWord is a term which acts as Not, e.g. "NOT". Leave empty to disable
+}
+
+// DefaultQuerySyntax supports queries like: (rock | metal) -live "let it be"
+var DefaultQuerySyntax = QuerySyntax{Or: '|', Not: '-', Open: '(', Close: ')', Quote: '"'}
+
+// QueryNode is a node of a parsed boolean query. One of *TermNode, *AndNode, *OrNode or *NotNode
+// Only the node types of this package implement it, so Evaluate handles every QueryNode
+type QueryNode interface {
+	String() string
+	queryNode()
+}
+
+// TermNode matches the words containing Term
+type TermNode struct {
+	Term string
+}
+
+// AndNode matches the words matching all of its children
+type AndNode struct {
+	Children []QueryNode
+}
+
+// OrNode matches the words matching any of its children
+type OrNode struct {
+	Children []QueryNode
+}
+
+// NotNode matches the words not matching its child
+type NotNode struct {
+	Child QueryNode
+}
+
+func (N *TermNode) String() string { return strconv.Quote(N.Term) }
+func (N *NotNode) String() string  { return "NOT " + N.Child.String() }
+func (N *AndNode) String() string  { return joinNodes(N.Children, " AND ") }
+func (N *OrNode) String() string   { return joinNodes(N.Children, " OR ") }
+
+func (N *TermNode) queryNode() {}
+func (N *NotNode) queryNode()  {}
+func (N *AndNode) queryNode()  {}
+func (N *OrNode) queryNode()   {}
+
+func joinNodes(Nodes []QueryNode, Separator string) string {
+	Strings := make([]string, len(Nodes))
+	for i, Node := range Nodes {
+		Strings[i] = Node.String()
+	}
+	return "(" + strings.Join(Strings, Separator) + ")"
+}
+
+// QueryError is returned when a boolean query can't be parsed
+type QueryError struct {
+	Query string // Query is the query which failed to parse
+	Pos   int    // Pos is the byte offset in Query where the error was found
+	Msg   string // Msg describes the error
+}
+
+func (E *QueryError) Error() string {
+	return fmt.Sprintf("ferret: %s at position %d in query %q", E.Msg, E.Pos, E.Query)
+}
+
+// Token kinds of the boolean query language
+const (
+	tokenTerm = iota
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+	tokenEnd
+)
+
+type queryToken struct {
+	Kind int
+	Pos  int
+	Text string
+}
+
+// tokenize splits a boolean query into tokens
+func tokenize(Query string, Syntax QuerySyntax) ([]queryToken, error) {
+	Tokens := make([]queryToken, 0)
+	isOperator := func(r rune) bool {
+		return r == Syntax.Or || r == Syntax.Open || r == Syntax.Close || r == Syntax.Quote
+	}
+	for i := 0; i < len(Query); {
+		r, n := utf8.DecodeRuneInString(Query[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += n
+		case r == Syntax.Or:
+			Tokens = append(Tokens, queryToken{tokenOr, i, Query[i : i+n]})
+			i += n
+		case r == Syntax.Open:
+			Tokens = append(Tokens, queryToken{tokenOpen, i, Query[i : i+n]})
+			i += n
+		case r == Syntax.Close:
+			Tokens = append(Tokens, queryToken{tokenClose, i, Query[i : i+n]})
+			i += n
+		case r == Syntax.Not:
+			Tokens = append(Tokens, queryToken{tokenNot, i, Query[i : i+n]})
+			i += n
+		case r == Syntax.Quote:
+			j := strings.IndexRune(Query[i+n:], Syntax.Quote)
+			if j < 0 {
+				return nil, &QueryError{Query, i, "unterminated quoted literal"}
+			}
+			Tokens = append(Tokens, queryToken{tokenTerm, i, Query[i+n : i+n+j]})
+			i += n + j + utf8.RuneLen(Syntax.Quote)
+		default:
+			j := i
+			for j < len(Query) {
+				r, n := utf8.DecodeRuneInString(Query[j:])
+				if unicode.IsSpace(r) || isOperator(r) {
+					break
+				}
+				j += n
+			}
+			Text := Query[i:j]
+			switch {
+			case Syntax.OrWord != "" && Text == Syntax.OrWord:
+				Tokens = append(Tokens, queryToken{tokenOr, i, Text})
+			case Syntax.NotWord != "" && Text == Syntax.NotWord:
+				Tokens = append(Tokens, queryToken{tokenNot, i, Text})
+			case Syntax.AndWord != "" && Text == Syntax.AndWord:
+			default:
+				Tokens = append(Tokens, queryToken{tokenTerm, i, Text})
+			}
+			i = j
+		}
+	}
+	return append(Tokens, queryToken{tokenEnd, len(Query), ""}), nil
+}
+
+// A recursive descent parser for the boolean query language:
+//     or      = and { Or and }
+//     and     = unary { unary }
+//     unary   = Not unary | primary
+//     primary = Open or Close | term
+type queryParser struct {
+	Query  string
+	Tokens []queryToken
+	Pos    int
+}
+
+func (P *queryParser) peek() queryToken {
+	return P.Tokens[P.Pos]
+}
+
+func (P *queryParser) errorf(Token queryToken, format string, args ...interface{}) error {
+	return &QueryError{P.Query, Token.Pos, fmt.Sprintf(format, args...)}
+}
+
+func (P *queryParser) parseOr() (QueryNode, error) {
+	Children := make([]QueryNode, 0, 1)
+	for {
+		Node, err := P.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		Children = append(Children, Node)
+		if P.peek().Kind != tokenOr {
+			break
+		}
+		P.Pos++
+	}
+	if len(Children) == 1 {
+		return Children[0], nil
+	}
+	return &OrNode{Children}, nil
+}
+
+func (P *queryParser) parseAnd() (QueryNode, error) {
+	Children := make([]QueryNode, 0, 1)
+	for {
+		Kind := P.peek().Kind
+		if Kind == tokenOr || Kind == tokenClose || Kind == tokenEnd {
+			break
+		}
+		Node, err := P.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		Children = append(Children, Node)
+	}
+	if len(Children) == 0 {
+		Token := P.peek()
+		if Token.Kind == tokenEnd {
+			return nil, P.errorf(Token, "expected term")
+		}
+		return nil, P.errorf(Token, "expected term before %q", Token.Text)
+	}
+	if len(Children) == 1 {
+		return Children[0], nil
+	}
+	return &AndNode{Children}, nil
+}
+
+func (P *queryParser) parseUnary() (QueryNode, error) {
+	if P.peek().Kind == tokenNot {
+		P.Pos++
+		Kind := P.peek().Kind
+		if Kind != tokenTerm && Kind != tokenOpen && Kind != tokenNot {
+			return nil, P.errorf(P.Tokens[P.Pos-1], "expected term after %q", P.Tokens[P.Pos-1].Text)
+		}
+		Child, err := P.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &NotNode{Child}, nil
+	}
+	return P.parsePrimary()
+}
+
+func (P *queryParser) parsePrimary() (QueryNode, error) {
+	Token := P.peek()
+	switch Token.Kind {
+	case tokenTerm:
+		P.Pos++
+		return &TermNode{Token.Text}, nil
+	case tokenOpen:
+		P.Pos++
+		Node, err := P.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if P.peek().Kind != tokenClose {
+			return nil, P.errorf(Token, "unclosed %q", Token.Text)
+		}
+		P.Pos++
+		return Node, nil
+	}
+	return nil, P.errorf(Token, "unexpected %q", Token.Text)
+}
+
+// ParseQuery parses a boolean query, e.g. (rock | metal) -live, using the given syntax
+// Returns a *QueryError if the query is malformed
+func ParseQuery(Query string, Syntax QuerySyntax) (QueryNode, error) {
+	Tokens, err := tokenize(Query, Syntax)
+	if err != nil {
+		return nil, err
+	}
+	P := &queryParser{Query, Tokens, 0}
+	Node, err := P.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if Token := P.peek(); Token.Kind != tokenEnd {
+		return nil, P.errorf(Token, "unexpected %q", Token.Text)
+	}
+	return Node, nil
+}
+
+// Evaluate returns the word indexes (sorted) of the words matching a parsed boolean query
+func (IS *InvertedSuffix) Evaluate(Node QueryNode) []int {
+	return IS.evaluate(nil, Node)
+}
+
+// evaluate is Evaluate, stopping early when G does. The set is then incomplete, and shouldn't be used
+func (IS *InvertedSuffix) evaluate(G *queryGuard, Node QueryNode) []int {
+	switch N := Node.(type) {
+	case *TermNode:
+		low, high := IS.Search(IS.Converter(N.Term))
+		return IS.rangeWords(G, low, high)
+	case *NotNode:
+		return differenceSet(IS.allWords(), IS.evaluate(G, N.Child))
+	case *OrNode:
+		Set := []int{}
+		for _, Child := range N.Children {
+			Set = unionSet(Set, IS.evaluate(G, Child))
+		}
+		return Set
+	case *AndNode:
+		// Intersect the positive children first, then subtract the negated ones
+		var Set []int
+		for _, Child := range N.Children {
+			if _, ok := Child.(*NotNode); ok {
+				continue
+			}
+			if Set == nil {
+				Set = IS.evaluate(G, Child)
+			} else {
+				Set = intersectSet(Set, IS.evaluate(G, Child))
+			}
+		}
+		if Set == nil {
+			Set = IS.allWords()
+		}
+		for _, Child := range N.Children {
+			if Not, ok := Child.(*NotNode); ok && len(Set) > 0 {
+				Set = differenceSet(Set, IS.evaluate(G, Not.Child))
+			}
+		}
+		return Set
+	}
+	panic(fmt.Sprintf("ferret: unknown query node %T", Node))
+}
+
+// BooleanQuery returns the strings matching a boolean query, e.g. (rock | metal) -live,
+// and their stored values, ordered by word index. Returns a *QueryError if the query is malformed
+// Input:
+//     Query: The boolean query. Terms are substrings to search for.
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Syntax: The operators of the query language, e.g. DefaultQuerySyntax
+func (IS *InvertedSuffix) BooleanQuery(Query string, ResultsLimit int, Syntax QuerySyntax) ([]string, []interface{}, error) {
+	return IS.BooleanQueryContext(context.Background(), Query, ResultsLimit, Syntax)
+}
+
+// BooleanQueryContext is like BooleanQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning why it stopped. It then returns no results,
+// since the words of a partly evaluated query may not match it
+func (IS *InvertedSuffix) BooleanQueryContext(ctx context.Context, Query string, ResultsLimit int, Syntax QuerySyntax) ([]string, []interface{}, error) {
+	Node, err := ParseQuery(Query, Syntax)
+	if err != nil {
+		return nil, nil, err
+	}
+	G := newQueryGuard(ctx)
+	Set := IS.evaluate(G, Node)
+	if G.err != nil {
+		return []string{}, []interface{}{}, G.err
+	}
+	if ResultsLimit >= 0 && len(Set) > ResultsLimit {
+		Set = Set[:ResultsLimit]
+	}
+	Results := make([]string, len(Set))
+	Values := make([]interface{}, len(Set))
+	for i, x := range Set {
+		Results[i] = IS.Results[x]
+		Values[i] = IS.Values[x]
+	}
+	return Results, Values, nil
+}
+
+// rangeWords returns the word indexes (sorted) of the suffixes in [low, high), or of those visited before G stops
+func (IS *InvertedSuffix) rangeWords(G *queryGuard, low, high int) []int {
+	Set := make([]int, 0, high-low)
+	used := make(map[int]bool, 0)
+	for k := low; k < high; k++ {
+		if !G.step(1) {
+			break
+		}
+		x := IS.WordIndex[k]
+		if _, ok := used[x]; ok {
+			continue
+		}
+		used[x] = true
+		Set = append(Set, x)
+	}
+	sort.Ints(Set)
+	return Set
+}
+
+// allWords returns the word indexes of every word
+func (IS *InvertedSuffix) allWords() []int {
+	Set := make([]int, len(IS.Words))
+	for i := range Set {
+		Set[i] = i
+	}
+	return Set
+}
+
+// Set operations over sorted word indexes
+
+func unionSet(a, b []int) []int {
+	Set := make([]int, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			Set = append(Set, a[i])
+			i++
+		case b[j] < a[i]:
+			Set = append(Set, b[j])
+			j++
+		default:
+			Set = append(Set, a[i])
+			i++
+			j++
+		}
+	}
+	Set = append(Set, a[i:]...)
+	return append(Set, b[j:]...)
+}
+
+func intersectSet(a, b []int) []int {
+	Set := make([]int, 0)
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case b[j] < a[i]:
+			j++
+		default:
+			Set = append(Set, a[i])
+			i++
+			j++
+		}
+	}
+	return Set
+}
+
+func differenceSet(a, b []int) []int {
+	Set := make([]int, 0, len(a))
+	j := 0
+	for _, x := range a {
+		for j < len(b) && b[j] < x {
+			j++
+		}
+		if j < len(b) && b[j] == x {
+			continue
+		}
+		Set = append(Set, x)
+	}
+	return Set
+}
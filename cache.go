@@ -0,0 +1,190 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// The key of a cached query. Queries are cached by their converted form
+type cacheKey struct {
+	Query  string
+	Limit  int
+	Mode   QueryMode
+	Sorted bool
+}
+
+type cacheEntry struct {
+	Key     cacheKey
+	Results []string
+	Values  []interface{}
+	Scores  []float64
+}
+
+// QueryCache is a least-recently-used cache of query results in front of an InvertedSuffix
+// The cache is emptied whenever the index changes (see InvertedSuffix.Generation).
+// Safe for concurrent use, as long as nothing inserts into the index at the same time.
+// Returned arrays are shared between callers, so don't modify them
+type QueryCache struct {
+	IS         *InvertedSuffix                             // IS is the cached index
+	Size       int                                         // Size is the maximum number of cached queries
+	Sorter     func(string, interface{}, int, int) float64 // Sorter is used by the sorted queries
+	mutex      sync.Mutex
+	hits       uint64
+	misses     uint64
+	generation uint64
+	entries    map[cacheKey]*list.Element
+	order      *list.List
+}
+
+// NewQueryCache creates a cache of up to Size queries of IS
+// Sorter is used by the sorted queries, and may be nil if they aren't used
+func NewQueryCache(IS *InvertedSuffix, Size int, Sorter func(string, interface{}, int, int) float64) *QueryCache {
+	return &QueryCache{
+		IS:         IS,
+		Size:       Size,
+		Sorter:     Sorter,
+		generation: IS.Generation,
+		entries:    make(map[cacheKey]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Stats returns the number of cache hits and misses so far
+func (QC *QueryCache) Stats() (uint64, uint64) {
+	QC.mutex.Lock()
+	defer QC.mutex.Unlock()
+	return QC.hits, QC.misses
+}
+
+// Purge empties the cache
+func (QC *QueryCache) Purge() {
+	QC.mutex.Lock()
+	defer QC.mutex.Unlock()
+	QC.purge()
+}
+
+func (QC *QueryCache) purge() {
+	QC.entries = make(map[cacheKey]*list.Element)
+	QC.order.Init()
+	QC.generation = QC.IS.Generation
+}
+
+// lookup returns the cached entry for Key, computing and caching it on a miss
+// Entries computed with an error (e.g. a cancelled query) are returned, but not cached
+func (QC *QueryCache) lookup(Key cacheKey, compute func() (*cacheEntry, error)) (*cacheEntry, error) {
+	QC.mutex.Lock()
+	if QC.generation != QC.IS.Generation {
+		QC.purge()
+	}
+	if Element, ok := QC.entries[Key]; ok {
+		QC.order.MoveToFront(Element)
+		QC.hits++
+		QC.mutex.Unlock()
+		return Element.Value.(*cacheEntry), nil
+	}
+	QC.misses++
+	Generation := QC.generation
+	QC.mutex.Unlock()
+
+	Entry, err := compute()
+	Entry.Key = Key
+	if err != nil {
+		return Entry, err
+	}
+
+	QC.mutex.Lock()
+	defer QC.mutex.Unlock()
+	if Generation != QC.IS.Generation || QC.Size <= 0 {
+		return Entry, nil
+	}
+	if _, ok := QC.entries[Key]; !ok {
+		QC.entries[Key] = QC.order.PushFront(Entry)
+		for QC.order.Len() > QC.Size {
+			Oldest := QC.order.Back()
+			QC.order.Remove(Oldest)
+			delete(QC.entries, Oldest.Value.(*cacheEntry).Key)
+		}
+	}
+	return Entry, nil
+}
+
+// Query is a cached InvertedSuffix.Query
+func (QC *QueryCache) Query(Word string, ResultsLimit int) ([]string, []interface{}) {
+	return QC.AnchoredQuery(Word, ResultsLimit, SubstringMode)
+}
+
+// QueryContext is a cached InvertedSuffix.QueryContext. Stopped queries aren't cached
+func (QC *QueryCache) QueryContext(ctx context.Context, Word string, ResultsLimit int) ([]string, []interface{}, error) {
+	return QC.AnchoredQueryContext(ctx, Word, ResultsLimit, SubstringMode)
+}
+
+// SortedQuery is a cached InvertedSuffix.SortedQuery, using the Sorter of the cache
+func (QC *QueryCache) SortedQuery(Word string, ResultsLimit int) ([]string, []interface{}, []float64) {
+	return QC.SortedAnchoredQuery(Word, ResultsLimit, SubstringMode)
+}
+
+// SortedQueryContext is a cached InvertedSuffix.SortedQueryContext, using the Sorter of the cache
+// Stopped queries aren't cached
+func (QC *QueryCache) SortedQueryContext(ctx context.Context, Word string, ResultsLimit int) ([]string, []interface{}, []float64, error) {
+	return QC.SortedAnchoredQueryContext(ctx, Word, ResultsLimit, SubstringMode)
+}
+
+// AnchoredQuery is a cached InvertedSuffix.AnchoredQuery
+func (QC *QueryCache) AnchoredQuery(Word string, ResultsLimit int, Mode QueryMode) ([]string, []interface{}) {
+	Results, Values, _ := QC.AnchoredQueryContext(context.Background(), Word, ResultsLimit, Mode)
+	return Results, Values
+}
+
+// AnchoredQueryContext is a cached InvertedSuffix.AnchoredQueryContext. Stopped queries aren't cached
+func (QC *QueryCache) AnchoredQueryContext(ctx context.Context, Word string, ResultsLimit int, Mode QueryMode) ([]string, []interface{}, error) {
+	Key := cacheKey{string(QC.IS.Converter(Word)), ResultsLimit, Mode, false}
+	Entry, err := QC.lookup(Key, func() (*cacheEntry, error) {
+		if Mode == SubstringMode {
+			Results, Values, err := QC.IS.QueryContext(ctx, Word, ResultsLimit)
+			return &cacheEntry{Results: Results, Values: Values}, err
+		}
+		Results, Values, err := QC.IS.AnchoredQueryContext(ctx, Word, ResultsLimit, Mode)
+		return &cacheEntry{Results: Results, Values: Values}, err
+	})
+	return Entry.Results, Entry.Values, err
+}
+
+// SortedAnchoredQuery is a cached InvertedSuffix.SortedAnchoredQuery, using the Sorter of the cache
+func (QC *QueryCache) SortedAnchoredQuery(Word string, ResultsLimit int, Mode QueryMode) ([]string, []interface{}, []float64) {
+	Results, Values, Scores, _ := QC.SortedAnchoredQueryContext(context.Background(), Word, ResultsLimit, Mode)
+	return Results, Values, Scores
+}
+
+// SortedAnchoredQueryContext is a cached InvertedSuffix.SortedAnchoredQueryContext, using the Sorter of the cache
+// Stopped queries aren't cached
+func (QC *QueryCache) SortedAnchoredQueryContext(ctx context.Context, Word string, ResultsLimit int, Mode QueryMode) ([]string, []interface{}, []float64, error) {
+	Key := cacheKey{string(QC.IS.Converter(Word)), ResultsLimit, Mode, true}
+	Entry, err := QC.lookup(Key, func() (*cacheEntry, error) {
+		if Mode == SubstringMode {
+			Results, Values, Scores, err := QC.IS.SortedQueryContext(ctx, Word, ResultsLimit, QC.Sorter)
+			return &cacheEntry{Results: Results, Values: Values, Scores: Scores}, err
+		}
+		Results, Values, Scores, err := QC.IS.SortedAnchoredQueryContext(ctx, Word, ResultsLimit, Mode, QC.Sorter)
+		return &cacheEntry{Results: Results, Values: Values, Scores: Scores}, err
+	})
+	return Entry.Results, Entry.Values, Entry.Scores, err
+}
@@ -0,0 +1,98 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Hiragana is all Hiragana runes (ぁ-ゖ)
+var Hiragana = runeRange('ぁ', 'ゖ')
+
+// Katakana is all Katakana runes (ァ-ヺ), plus the prolonged sound mark (ー)
+var Katakana = append(runeRange('ァ', 'ヺ'), 'ー')
+
+func runeRange(lo, hi rune) []rune {
+	Runes := make([]rune, 0, hi-lo+1)
+	for r := lo; r <= hi; r++ {
+		Runes = append(Runes, r)
+	}
+	return Runes
+}
+
+// Half-width katakana (U+FF61-U+FF9F) to their full-width forms
+var halfWidthKatakana = []rune("。「」、・ヲァィゥェォャュョッーアイウエオカキクケコサシスセソタチツテトナニヌネノハヒフヘホマミムメモヤユヨラリルレロワン゛゜")
+
+// Full-width katakana which combine with a following (semi-)voiced sound mark
+var (
+	voicedKatakana     = "カキクケコサシスセソタチツテトハヒフヘホ"
+	semiVoicedKatakana = "ハヒフヘホ"
+)
+
+// NormalizeWidth converts full-width ASCII (and the ideographic space) to half-width ASCII,
+// and half-width katakana to full-width katakana, composing voiced sound marks
+func NormalizeWidth(s string) []byte {
+	Result := make([]byte, 0, len(s))
+	var buf [utf8.UTFMax]byte
+	Runes := []rune(s)
+	for i := 0; i < len(Runes); i++ {
+		r := Runes[i]
+		switch {
+		case r == '　':
+			r = ' '
+		case r >= '！' && r <= '～':
+			r -= 0xFEE0
+		case r >= '｡' && r <= 'ﾟ':
+			r = halfWidthKatakana[r-'｡']
+			if i+1 < len(Runes) {
+				switch {
+				case Runes[i+1] == 'ﾞ' && r == 'ウ':
+					r = 'ヴ'
+					i++
+				case Runes[i+1] == 'ﾞ' && strings.ContainsRune(voicedKatakana, r):
+					r++
+					i++
+				case Runes[i+1] == 'ﾟ' && strings.ContainsRune(semiVoicedKatakana, r):
+					r += 2
+					i++
+				}
+			}
+		}
+		n := utf8.EncodeRune(buf[:], r)
+		Result = append(Result, buf[:n]...)
+	}
+	return Result
+}
+
+// HiraganaToKatakana converts Hiragana to Katakana, so that queries in either kana match both
+func HiraganaToKatakana(s string) []byte {
+	return []byte(strings.Map(func(r rune) rune {
+		if r >= 'ぁ' && r <= 'ゖ' || r == 'ゝ' || r == 'ゞ' {
+			return r + 0x60
+		}
+		return r
+	}, s))
+}
+
+// CJKToLowerASCII normalizes width and kana, then converts to lowercase ASCII like UnicodeToLowerASCII
+// Use with NewRuneAligned and ErrorCorrectRunes for CJK dictionaries
+func CJKToLowerASCII(s string) []byte {
+	return UnicodeToLowerASCII(string(HiraganaToKatakana(string(NormalizeWidth(s)))))
+}
@@ -0,0 +1,171 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+)
+
+// MatchComparator orders two matches for ComparedQuery, returning a negative number if a comes first,
+// a positive number if b comes first, or 0 if they're tied
+type MatchComparator func(a, b *Match) int
+
+// ScoreDescending orders matches by a score, largest first
+func ScoreDescending(Score func(*Match) float64) MatchComparator {
+	return func(a, b *Match) int {
+		s, t := Score(a), Score(b)
+		if s > t {
+			return -1
+		}
+		if s < t {
+			return 1
+		}
+		return 0
+	}
+}
+
+// ScoreAscending orders matches by a score, smallest first
+// e.g. ScoreAscending(func(M *Match) float64 { return float64(M.Length) }) for shortest first
+func ScoreAscending(Score func(*Match) float64) MatchComparator {
+	Descending := ScoreDescending(Score)
+	return func(a, b *Match) int {
+		return Descending(b, a)
+	}
+}
+
+// Alphabetical orders matches by their string value (Result), then their converted word
+func Alphabetical(a, b *Match) int {
+	if c := strings.Compare(a.Result, b.Result); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.Word, b.Word)
+}
+
+// ByWordID orders matches by their word index, which is their order of insertion
+func ByWordID(a, b *Match) int {
+	return a.WordID - b.WordID
+}
+
+// Lexicographic orders matches by each comparator in turn, moving on to the next on a tie,
+// and finally by word index, so no two matches are tied
+// e.g. Lexicographic(ScoreDescending(Popularity), ScoreAscending(Length), Alphabetical)
+func Lexicographic(Comparators ...MatchComparator) MatchComparator {
+	return func(a, b *Match) int {
+		for _, Compare := range Comparators {
+			if c := Compare(a, b); c != 0 {
+				return c
+			}
+		}
+		return ByWordID(a, b)
+	}
+}
+
+// compareMatches adds a Match for each word containing Corrected to Best,
+// replacing the match of a word already in Best if the new one comes first, unless G stops it
+func (IS *InvertedSuffix) compareMatches(G *queryGuard, Best map[int]*Match, Query, Corrected []byte, Distance int, Compare MatchComparator) {
+	Order, Positions := IS.matchPositions(G, Corrected)
+	for _, x := range Order {
+		M := IS.newMatch(x, Positions[x], Query, Corrected, Distance)
+		if Previous, ok := Best[x]; !ok || Compare(M, Previous) < 0 {
+			Best[x] = M
+		}
+	}
+}
+
+// sortMatches returns the results and values of the matches in Best, in order, limited to ResultsLimit
+func (IS *InvertedSuffix) sortMatches(Best map[int]*Match, ResultsLimit int, Compare MatchComparator) ([]string, []interface{}) {
+	Matches := make([]*Match, 0, len(Best))
+	for _, M := range Best {
+		Matches = append(Matches, M)
+	}
+	sort.Slice(Matches, func(i, j int) bool {
+		return Compare(Matches[i], Matches[j]) < 0
+	})
+	if ResultsLimit >= 0 && len(Matches) > ResultsLimit {
+		Matches = Matches[:ResultsLimit]
+	}
+	Results := make([]string, len(Matches))
+	Values := make([]interface{}, len(Matches))
+	for i, M := range Matches {
+		Results[i] = M.Result
+		Values[i] = M.Value
+	}
+	return Results, Values
+}
+
+// ComparedQuery returns the strings which contain the query, and their stored values,
+// in the order given by a comparator. Ties are broken by word index, so the order is deterministic
+// Input:
+//     Word: The substring to search for.
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Compare: Orders two matches (*Match, *Match), e.g. Lexicographic(ScoreDescending(Popularity), Alphabetical)
+func (IS *InvertedSuffix) ComparedQuery(Word string, ResultsLimit int, Compare MatchComparator) ([]string, []interface{}) {
+	Results, Values, _ := IS.ComparedQueryContext(context.Background(), Word, ResultsLimit, Compare)
+	return Results, Values
+}
+
+// ComparedQueryContext is like ComparedQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning why it stopped. Words are only compared once all of their positions
+// are found, so it returns no results if it stops
+func (IS *InvertedSuffix) ComparedQueryContext(ctx context.Context, Word string, ResultsLimit int, Compare MatchComparator) ([]string, []interface{}, error) {
+	Query := IS.Converter(Word)
+	Compare = Lexicographic(Compare)
+	G := newQueryGuard(ctx)
+	Best := make(map[int]*Match, 0)
+	IS.compareMatches(G, Best, Query, Query, 0, Compare)
+	Results, Values := IS.sortMatches(Best, ResultsLimit, Compare)
+	return Results, Values, G.err
+}
+
+// ComparedErrorCorrectingQuery returns the strings which contain the query, and their stored values,
+// in the order given by a comparator. Ties are broken by word index, so the order is deterministic
+// Will search for all substrings defined by ErrorCorrection
+// if no results are found on the initial query. Each word is ordered by its best match
+// Input:
+//     Word: The substring to search for.
+//     ResultsLimit: Limit the results so you don't return your whole dictionary by accident. Set to -1 for no limit
+//     ErrorCorrection: Returns a list of alternate queries
+//     Compare: Orders two matches (*Match, *Match), e.g. Lexicographic(ScoreDescending(Popularity), Alphabetical)
+func (IS *InvertedSuffix) ComparedErrorCorrectingQuery(Word string, ResultsLimit int, ErrorCorrection func([]byte) [][]byte, Compare MatchComparator) ([]string, []interface{}) {
+	Results, Values, _ := IS.ComparedErrorCorrectingQueryContext(context.Background(), Word, ResultsLimit, ErrorCorrection, Compare)
+	return Results, Values
+}
+
+// ComparedErrorCorrectingQueryContext is like ComparedErrorCorrectingQuery, but stops early when ctx is done
+// or its work budget (see WithWorkBudget) is exceeded, returning the results of the queries finished so far and why it stopped
+func (IS *InvertedSuffix) ComparedErrorCorrectingQueryContext(ctx context.Context, Word string, ResultsLimit int, ErrorCorrection func([]byte) [][]byte, Compare MatchComparator) ([]string, []interface{}, error) {
+	Query := IS.Converter(Word)
+	Compare = Lexicographic(Compare)
+	G := newQueryGuard(ctx)
+	Best := make(map[int]*Match, 0)
+	IS.compareMatches(G, Best, Query, Query, 0, Compare)
+	if len(Best) == 0 {
+		for _, q := range ErrorCorrection(Query) {
+			if !G.step(1) {
+				break
+			}
+			IS.compareMatches(G, Best, Query, q, 1, Compare)
+		}
+	}
+	Results, Values := IS.sortMatches(Best, ResultsLimit, Compare)
+	return Results, Values, G.err
+}
@@ -0,0 +1,79 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrWorkBudgetExceeded is returned by the Context queries when the work budget of their context is used up
+var ErrWorkBudgetExceeded = errors.New("ferret: work budget exceeded")
+
+// How much work is done between checks of the context
+const checkInterval = 256
+
+type workBudgetKey struct{}
+
+// WithWorkBudget returns a copy of ctx limiting each Context query to Budget units of work,
+// where a unit is one suffix match or candidate word visited, or one alternate query or field searched
+func WithWorkBudget(ctx context.Context, Budget int) context.Context {
+	return context.WithValue(ctx, workBudgetKey{}, Budget)
+}
+
+// queryGuard tracks the work done by a query, and whether it should stop
+type queryGuard struct {
+	ctx     context.Context
+	budget  int
+	work    int
+	checked int
+	err     error
+}
+
+func newQueryGuard(ctx context.Context) *queryGuard {
+	Budget, ok := ctx.Value(workBudgetKey{}).(int)
+	if !ok {
+		Budget = -1
+	}
+	return &queryGuard{ctx: ctx, budget: Budget, checked: -checkInterval}
+}
+
+// step records n units of work, returning false once the query should stop. The reason is left in err
+// A nil guard never stops
+func (G *queryGuard) step(n int) bool {
+	if G == nil {
+		return true
+	}
+	if G.err != nil {
+		return false
+	}
+	G.work += n
+	if G.budget >= 0 && G.work > G.budget {
+		G.err = ErrWorkBudgetExceeded
+		return false
+	}
+	if G.work-G.checked >= checkInterval {
+		G.checked = G.work
+		if err := G.ctx.Err(); err != nil {
+			G.err = err
+			return false
+		}
+	}
+	return true
+}
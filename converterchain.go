@@ -0,0 +1,217 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Span is the bytes [Start, End) of a string
+type Span struct {
+	Start int
+	End   int
+}
+
+// ConverterStage is a single normalization step of a ConverterChain
+// Returns the converted string, and for each of its bytes, the span of s it came from
+// (e.g. the whole of a replaced string, for each byte of its replacement).
+// Inserted bytes may report an empty span at their position in s
+type ConverterStage func(s string) (string, []Span)
+
+// ConverterChain composes normalization stages, applied in order
+// Use ConverterChain.Convert as the Converter of an InvertedSuffix
+type ConverterChain []ConverterStage
+
+// Convert applies each stage of the chain to s in order
+func (CC ConverterChain) Convert(s string) []byte {
+	for _, Stage := range CC {
+		s, _ = Stage(s)
+	}
+	return []byte(s)
+}
+
+// ConvertOffsets applies each stage of the chain to s in order
+// Also returns, for each byte of the result, the span of s it came from,
+// so that matches in the converted word can be mapped back to the original (see OriginalSpan)
+func (CC ConverterChain) ConvertOffsets(s string) ([]byte, []Span) {
+	Spans := make([]Span, len(s))
+	for i := range Spans {
+		Spans[i] = Span{i, i + 1}
+	}
+	for _, Stage := range CC {
+		var StageSpans []Span
+		s, StageSpans = Stage(s)
+		for i, Source := range StageSpans {
+			Start, End := OriginalSpan(Spans, Source.Start, Source.End)
+			StageSpans[i] = Span{Start, End}
+		}
+		Spans = StageSpans
+	}
+	return []byte(s), Spans
+}
+
+// OriginalSpan maps the bytes [Start, End) of a word converted by ConvertOffsets
+// back to the bytes [start, end) of the original string
+// e.g. to highlight a match at Index with length len(Query): OriginalSpan(Spans, Index, Index+len(Query))
+// Start and End are clamped to the converted word, and an empty span maps to an empty span at its position
+func OriginalSpan(Spans []Span, Start, End int) (int, int) {
+	if End > len(Spans) {
+		End = len(Spans)
+	}
+	if Start < 0 {
+		Start = 0
+	}
+	if Start >= End {
+		if Start < len(Spans) {
+			return Spans[Start].Start, Spans[Start].Start
+		}
+		if len(Spans) > 0 {
+			return Spans[len(Spans)-1].End, Spans[len(Spans)-1].End
+		}
+		return 0, 0
+	}
+	return Spans[Start].Start, Spans[End-1].End
+}
+
+// RuneStage creates a stage replacing each rune with the string returned by f
+func RuneStage(f func(rune) string) ConverterStage {
+	return func(s string) (string, []Span) {
+		Result := make([]byte, 0, len(s))
+		Spans := make([]Span, 0, len(s))
+		for i, r := range s {
+			t := f(r)
+			Result = append(Result, t...)
+			_, n := utf8.DecodeRuneInString(s[i:])
+			for j := 0; j < len(t); j++ {
+				Spans = append(Spans, Span{i, i + n})
+			}
+		}
+		return string(Result), Spans
+	}
+}
+
+// MapStage creates a stage mapping each rune with f, like strings.Map
+// If f returns a negative value, the rune is dropped
+func MapStage(f func(rune) rune) ConverterStage {
+	return RuneStage(func(r rune) string {
+		r = f(r)
+		if r < 0 {
+			return ""
+		}
+		return string(r)
+	})
+}
+
+// FoldAccents is a stage that removes accents using the UnicodeToASCII table
+var FoldAccents = MapStage(ToASCII)
+
+// Lowercase is a stage that converts to lowercase
+var Lowercase = MapStage(unicode.ToLower)
+
+// StripPunctuation is a stage that removes unicode punctuation
+var StripPunctuation = MapStage(func(r rune) rune {
+	if unicode.IsPunct(r) {
+		return -1
+	}
+	return r
+})
+
+// CollapseWhitespace is a stage that replaces each run of whitespace with a single space,
+// and removes leading and trailing whitespace
+func CollapseWhitespace(s string) (string, []Span) {
+	Result := make([]byte, 0, len(s))
+	Spans := make([]Span, 0, len(s))
+	Space := -1
+	for i, r := range s {
+		if unicode.IsSpace(r) {
+			if Space < 0 {
+				Space = i
+			}
+			continue
+		}
+		if Space >= 0 && len(Result) > 0 {
+			// The space comes from the whole run of whitespace
+			Result = append(Result, ' ')
+			Spans = append(Spans, Span{Space, i})
+		}
+		Space = -1
+		n := len(Result)
+		Result = append(Result, string(r)...)
+		for j := n; j < len(Result); j++ {
+			Spans = append(Spans, Span{i, i + len(Result) - n})
+		}
+	}
+	return string(Result), Spans
+}
+
+// RemoveCharacters creates a stage that removes every rune in Characters
+func RemoveCharacters(Characters string) ConverterStage {
+	return MapStage(func(r rune) rune {
+		if strings.ContainsRune(Characters, r) {
+			return -1
+		}
+		return r
+	})
+}
+
+// TransliterateStage creates a stage that transliterates using the given tables. See Transliterate
+func TransliterateStage(Tables ...map[rune]string) ConverterStage {
+	return RuneStage(func(r rune) string {
+		return Transliterate(string(r), Tables...)
+	})
+}
+
+// Replace creates a stage that replaces strings with other strings, given as old, new pairs
+// At each position, the first matching old string in argument order is replaced
+func Replace(OldNew ...string) ConverterStage {
+	if len(OldNew)%2 == 1 {
+		panic("ferret.Replace: odd argument count")
+	}
+	return func(s string) (string, []Span) {
+		Result := make([]byte, 0, len(s))
+		Spans := make([]Span, 0, len(s))
+		for i := 0; i < len(s); {
+			found := false
+			for j := 0; j < len(OldNew); j += 2 {
+				Old, New := OldNew[j], OldNew[j+1]
+				if Old == "" || !strings.HasPrefix(s[i:], Old) {
+					continue
+				}
+				Result = append(Result, New...)
+				for k := 0; k < len(New); k++ {
+					Spans = append(Spans, Span{i, i + len(Old)})
+				}
+				i += len(Old)
+				found = true
+				break
+			}
+			if !found {
+				_, n := utf8.DecodeRuneInString(s[i:])
+				Result = append(Result, s[i:i+n]...)
+				for k := 0; k < n; k++ {
+					Spans = append(Spans, Span{i, i + n})
+				}
+				i += n
+			}
+		}
+		return string(Result), Spans
+	}
+}
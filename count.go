@@ -0,0 +1,92 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"context"
+)
+
+// distinctWords calls f once for each word with a suffix in [low, high), until G stops it
+// Small ranges remember the words seen in a map, and large ranges in a bitset over all words
+func (IS *InvertedSuffix) distinctWords(G *queryGuard, low, high int, f func(x int)) {
+	if (high-low)*64 < len(IS.Words) {
+		used := make(map[int]bool, high-low)
+		for k := low; k < high; k++ {
+			if !G.step(1) {
+				return
+			}
+			x := IS.WordIndex[k]
+			if _, ok := used[x]; ok {
+				continue
+			}
+			used[x] = true
+			f(x)
+		}
+		return
+	}
+	used := newBitset(len(IS.Words))
+	for k := low; k < high; k++ {
+		if !G.step(1) {
+			return
+		}
+		x := IS.WordIndex[k]
+		if used.has(x) {
+			continue
+		}
+		used.set(x)
+		f(x)
+	}
+}
+
+// Count returns the number of words which contain the query,
+// without building the results like Query(Word, -1)
+func (IS *InvertedSuffix) Count(Word string) int {
+	n, _ := IS.CountContext(context.Background(), Word)
+	return n
+}
+
+// CountContext is like Count, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the number of words counted so far and why it stopped
+func (IS *InvertedSuffix) CountContext(ctx context.Context, Word string) (int, error) {
+	G := newQueryGuard(ctx)
+	low, high := IS.Search(IS.Converter(Word))
+	n := 0
+	IS.distinctWords(G, low, high, func(x int) { n++ })
+	return n, G.err
+}
+
+// Facet returns the number of words which contain the query for each key,
+// where Key produces the key of a word from its stored value
+// Input:
+//     Word: The substring to search for.
+//     Key: Takes the Value of a word (interface{}) and produces the key (string) to count it under
+func (IS *InvertedSuffix) Facet(Word string, Key func(interface{}) string) map[string]int {
+	Counts, _ := IS.FacetContext(context.Background(), Word, Key)
+	return Counts
+}
+
+// FacetContext is like Facet, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the counts so far and why it stopped
+func (IS *InvertedSuffix) FacetContext(ctx context.Context, Word string, Key func(interface{}) string) (map[string]int, error) {
+	G := newQueryGuard(ctx)
+	low, high := IS.Search(IS.Converter(Word))
+	Counts := make(map[string]int)
+	IS.distinctWords(G, low, high, func(x int) { Counts[Key(IS.Values[x])]++ })
+	return Counts, G.err
+}
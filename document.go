@@ -0,0 +1,197 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"context"
+)
+
+// DocumentIndex implements substring searches over documents with several named fields
+// (e.g. song title, artist and album), using an InvertedSuffix per field
+type DocumentIndex struct {
+	Fields  []string          // Fields is the names of the indexed fields
+	Indexes []*InvertedSuffix // Indexes[f] is over field Fields[f] of each document. Its Values are the document IDs
+	Results []string          // Results[ID] is the string value of the document with that ID. Used as a return value
+	Values  []interface{}     // Values[ID] is some data mapped to the document with that ID
+}
+
+// NewDocumentIndex creates a document index from the field names, the field values of each document
+// (Documents[ID][f] is the value of Fields[f] for the document ID), mapping data, and a string->[]byte converter
+// Empty field values are not indexed
+func NewDocumentIndex(Fields []string, Documents [][]string, Results []string, Data []interface{}, Converter func(string) []byte) *DocumentIndex {
+	Indexes := make([]*InvertedSuffix, len(Fields))
+	for f := range Fields {
+		Words := make([]string, 0, len(Documents))
+		IDs := make([]interface{}, 0, len(Documents))
+		for ID, Document := range Documents {
+			if f < len(Document) && Document[f] != "" {
+				Words = append(Words, Document[f])
+				IDs = append(IDs, ID)
+			}
+		}
+		Indexes[f] = New(Words, Words, IDs, Converter)
+	}
+	return &DocumentIndex{Fields, Indexes, Results, Data}
+}
+
+// Insert adds a document with the given field values, returning its ID
+func (DI *DocumentIndex) Insert(Document []string, Result string, Data interface{}) int {
+	ID := len(DI.Results)
+	DI.Results = append(DI.Results, Result)
+	DI.Values = append(DI.Values, Data)
+	for f, IS := range DI.Indexes {
+		if f < len(Document) && Document[f] != "" {
+			IS.add(IS.Converter(Document[f]), Document[f], ID)
+		}
+	}
+	return ID
+}
+
+// fieldIndexes returns the positions of the named fields, or all fields if Fields is empty
+func (DI *DocumentIndex) fieldIndexes(Fields []string) []int {
+	Positions := make([]int, 0, len(DI.Fields))
+	for f, Field := range DI.Fields {
+		if len(Fields) == 0 {
+			Positions = append(Positions, f)
+			continue
+		}
+		for _, Name := range Fields {
+			if Name == Field {
+				Positions = append(Positions, f)
+				break
+			}
+		}
+	}
+	return Positions
+}
+
+// Query returns the documents with a field containing the query, their stored values,
+// and which field matched, unsorted
+// Input:
+//     Word: The substring to search for.
+//     Fields: The names of the fields to search. Set to nil to search all fields
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+func (DI *DocumentIndex) Query(Word string, Fields []string, ResultsLimit int) ([]string, []interface{}, []string) {
+	Results, Values, Matched, _ := DI.QueryContext(context.Background(), Word, Fields, ResultsLimit)
+	return Results, Values, Matched
+}
+
+// QueryContext is like Query, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (DI *DocumentIndex) QueryContext(ctx context.Context, Word string, Fields []string, ResultsLimit int) ([]string, []interface{}, []string, error) {
+	if ResultsLimit == 0 {
+		return []string{}, []interface{}{}, []string{}, nil
+	}
+	if ResultsLimit < 0 {
+		ResultsLimit = 0
+	}
+	Results := make([]string, 0, ResultsLimit)
+	Values := make([]interface{}, 0, ResultsLimit)
+	Matched := make([]string, 0, ResultsLimit)
+	G := newQueryGuard(ctx)
+	a := 0
+	used := make(map[int]bool, 0)
+	for _, f := range DI.fieldIndexes(Fields) {
+		// Each field searched is a unit of work, so the guard is checked even when no field matches
+		if !G.step(1) {
+			return Results, Values, Matched, G.err
+		}
+		IS := DI.Indexes[f]
+		low, high := IS.Search(IS.Converter(Word))
+		for k := low; k < high; k++ {
+			if !G.step(1) {
+				return Results, Values, Matched, G.err
+			}
+			ID := IS.Values[IS.WordIndex[k]].(int)
+			if _, ok := used[ID]; ok {
+				continue
+			}
+			used[ID] = true
+			Results = append(Results, DI.Results[ID])
+			Values = append(Values, DI.Values[ID])
+			Matched = append(Matched, DI.Fields[f])
+			a++
+			if a == ResultsLimit {
+				return Results, Values, Matched, nil
+			}
+		}
+	}
+	return Results, Values, Matched, nil
+}
+
+// SortedQuery returns the documents with a field containing the query sorted,
+// and which field matched. Each document is scored by its best scoring field match
+// Input:
+//     Word: The substring to search for.
+//     Fields: The names of the fields to search. Set to nil to search all fields
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Sorter: Takes (Result, Value, Field, Length (of the field value), Index (where Query begins in the field value))
+//         (string, interface{}, string, int, int) and produces a value (float64) to sort by (largest first).
+func (DI *DocumentIndex) SortedQuery(Word string, Fields []string, ResultsLimit int, Sorter func(string, interface{}, string, int, int) float64) ([]string, []interface{}, []float64, []string) {
+	Results, Values, Scores, Matched, _ := DI.SortedQueryContext(context.Background(), Word, Fields, ResultsLimit, Sorter)
+	return Results, Values, Scores, Matched
+}
+
+// SortedQueryContext is like SortedQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (DI *DocumentIndex) SortedQueryContext(ctx context.Context, Word string, Fields []string, ResultsLimit int, Sorter func(string, interface{}, string, int, int) float64) ([]string, []interface{}, []float64, []string, error) {
+	G := newQueryGuard(ctx)
+	Top := newTopResults(ResultsLimit)
+Search:
+	for _, f := range DI.fieldIndexes(Fields) {
+		if !G.step(1) {
+			break
+		}
+		IS := DI.Indexes[f]
+		low, high := IS.Search(IS.Converter(Word))
+		for k := low; k < high; k++ {
+			if !G.step(1) {
+				break Search
+			}
+			x := IS.WordIndex[k]
+			ID := IS.Values[x].(int)
+			w := DI.Results[ID]
+			v := DI.Values[ID]
+			Top.add(ID, f, w, v, Sorter(w, v, DI.Fields[f], len(IS.Words[x]), IS.SuffixIndex[k]))
+		}
+	}
+	Results, Values, Scores := Top.split()
+	Matched := make([]string, len(Results))
+	for i, r := range Top.results() {
+		Matched[i] = DI.Fields[r.Aux]
+	}
+	return Results, Values, Scores, Matched, G.err
+}
+
+// FieldBoostSorter creates a DocumentIndex sorter which boosts the score of Sorter by the boost of the matched field:
+// a boost above 1 ranks matches in that field higher, and below 1 lower. Fields without a boost are not boosted
+// Positive scores are multiplied by the boost, and negative scores (e.g. of a length sorter) divided by it,
+// so a boost keeps its direction either way. Boosts must be positive
+func FieldBoostSorter(Boosts map[string]float64, Sorter func(string, interface{}, int, int) float64) func(string, interface{}, string, int, int) float64 {
+	return func(Result string, Value interface{}, Field string, Length, Index int) float64 {
+		s := Sorter(Result, Value, Length, Index)
+		if Boost, ok := Boosts[Field]; ok {
+			if s < 0 {
+				return s / Boost
+			}
+			return s * Boost
+		}
+		return s
+	}
+}
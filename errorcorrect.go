@@ -94,3 +94,160 @@ func ErrorCorrect(Word []byte, AllowedBytes []byte) [][]byte {
 	}
 	return results
 }
+
+// ErrorCorrectRunes returns all byte-arrays which are Levenshtein distance of 1 away from Word,
+// treating Word as UTF-8 and editing whole runes within an allowed array of runes.
+// Use this instead of ErrorCorrect with NewRuneAligned
+func ErrorCorrectRunes(Word []byte, AllowedRunes []rune) [][]byte {
+	results := make([][]byte, 0)
+	Runes := []rune(string(Word))
+	N := len(Runes)
+	for i := 0; i < N; i++ {
+		t := Runes[i]
+		// Remove Character
+		temp := make([]rune, N)
+		copy(temp, Runes)
+		temp = append(temp[:i], temp[i+1:]...)
+		results = append(results, []byte(string(temp)))
+		if i != 0 {
+			// Add Character
+			for _, c := range AllowedRunes {
+				temp := make([]rune, N)
+				copy(temp, Runes)
+				temp = append(temp[:i], append([]rune{c}, temp[i:]...)...)
+				results = append(results, []byte(string(temp)))
+			}
+			// Transpose Character
+			temp := make([]rune, N)
+			copy(temp, Runes)
+			temp[i], temp[i-1] = temp[i-1], temp[i]
+			results = append(results, []byte(string(temp)))
+		}
+		// Insert Character
+		for _, c := range AllowedRunes {
+			if c == t {
+				continue
+			}
+			temp := make([]rune, N)
+			copy(temp, Runes)
+			temp[i] = c
+			results = append(results, []byte(string(temp)))
+		}
+	}
+	return results
+}
+
+// EditDistance returns the number of bytes which must be removed, added, substituted,
+// or transposed with the next byte to turn a into b (the optimal string alignment distance)
+func EditDistance(a, b []byte) int {
+	A := make([]int, len(a))
+	for i, c := range a {
+		A[i] = int(c)
+	}
+	B := make([]int, len(b))
+	for i, c := range b {
+		B[i] = int(c)
+	}
+	return editDistance(A, B)
+}
+
+// editDistance is EditDistance over characters (bytes or runes)
+func editDistance(a, b []int) int {
+	// Rows i-2, i-1 and i of the distances between a[:i] and b[:j]
+	Prev2 := make([]int, len(b)+1)
+	Prev := make([]int, len(b)+1)
+	Row := make([]int, len(b)+1)
+	for j := range Prev {
+		Prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		Row[0] = i
+		for j := 1; j <= len(b); j++ {
+			Cost := 1
+			if a[i-1] == b[j-1] {
+				Cost = 0
+			}
+			d := Prev[j-1] + Cost
+			if Prev[j]+1 < d {
+				d = Prev[j] + 1
+			}
+			if Row[j-1]+1 < d {
+				d = Row[j-1] + 1
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && Prev2[j-2]+1 < d {
+				d = Prev2[j-2] + 1
+			}
+			Row[j] = d
+		}
+		Prev2, Prev, Row = Prev, Row, Prev2
+	}
+	return Prev[len(b)]
+}
+
+// withinDistance returns whether editDistance(a, b) <= t
+// Only the diagonal band of width t is computed, stopping early once every distance in a row is over t
+func withinDistance(a, b []int, t int) bool {
+	if len(a)-len(b) > t || len(b)-len(a) > t {
+		return false
+	}
+	Over := t + 1
+	// Rows i-2, i-1 and i of the distances between a[:i] and b[:j], capped at Over
+	Prev2 := make([]int, len(b)+1)
+	Prev := make([]int, len(b)+1)
+	Row := make([]int, len(b)+1)
+	for j := range Prev {
+		Prev[j] = j
+		if j > Over {
+			Prev[j] = Over
+		}
+	}
+	for i := 1; i <= len(a); i++ {
+		Low, High := i-t, i+t
+		if Low < 1 {
+			Low = 1
+		}
+		if High > len(b) {
+			High = len(b)
+		}
+		if i <= t {
+			Row[0] = i
+		} else {
+			Row[0] = Over
+		}
+		if Low > 1 {
+			Row[Low-1] = Over
+		}
+		Best := Row[0]
+		for j := Low; j <= High; j++ {
+			Cost := 1
+			if a[i-1] == b[j-1] {
+				Cost = 0
+			}
+			d := Prev[j-1] + Cost
+			if Prev[j]+1 < d {
+				d = Prev[j] + 1
+			}
+			if Row[j-1]+1 < d {
+				d = Row[j-1] + 1
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && Prev2[j-2]+1 < d {
+				d = Prev2[j-2] + 1
+			}
+			if d > Over {
+				d = Over
+			}
+			Row[j] = d
+			if d < Best {
+				Best = d
+			}
+		}
+		if High < len(b) {
+			Row[High+1] = Over
+		}
+		if Best > t {
+			return false
+		}
+		Prev2, Prev, Row = Prev, Row, Prev2
+	}
+	return Prev[len(b)] <= t
+}
@@ -30,6 +30,7 @@ import (
 var Correction = func(b []byte) [][]byte { return ferret.ErrorCorrect(b, ferret.LowercaseLetters) }
 var LengthSorter = func(s string, v interface{}, l int, i int) float64 { return -float64(l + i) }
 var FreqSorter = func(s string, v interface{}, l int, i int) float64 { return float64(v.(uint64)) }
+var Frequency = func(v interface{}) float64 { return float64(v.(uint64)) }
 var Converter = ferret.UnicodeToLowerASCII
 
 func main() {
@@ -74,6 +75,9 @@ func main() {
 	fmt.Println(SearchEngine.SortedErrorCorrectingQuery("tssst", 5, Correction, FreqSorter))
 	fmt.Println("Performed sorted error correcting search in:", time.Now().Sub(t))
 	t = time.Now()
+	fmt.Println(SearchEngine.Suggest("recieve", 5, Correction, Frequency))
+	fmt.Println("Performed suggestion in:", time.Now().Sub(t))
+	t = time.Now()
 	fmt.Println(SearchEngine.SortedQuery("a", 5, LengthSorter))
 	fmt.Println("Performed sorted search in:", time.Now().Sub(t))
 	t = time.Now()
@@ -0,0 +1,81 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/argusdusty/Ferret"
+	"math/rand"
+)
+
+// Checks Search against a brute force scan of every suffix, over random words from a small alphabet,
+// where many suffixes share long prefixes. Run with: go run searchcheck.go
+
+// randomWords returns n random words of 1 to Length characters from Alphabet
+func randomWords(r *rand.Rand, n, Length int, Alphabet []string) []string {
+	Words := make([]string, n)
+	for i := range Words {
+		Word := ""
+		for j := r.Intn(Length) + 1; j > 0; j-- {
+			Word += Alphabet[r.Intn(len(Alphabet))]
+		}
+		Words[i] = Word
+	}
+	return Words
+}
+
+// checkSearch returns an error if the range returned by Search isn't exactly the suffixes starting with Query
+func checkSearch(IS *ferret.InvertedSuffix, Query []byte) error {
+	low, high := IS.Search(Query)
+	for k := range IS.WordIndex {
+		Suffix := IS.Words[IS.WordIndex[k]][IS.SuffixIndex[k]:]
+		if bytes.HasPrefix(Suffix, Query) != (low <= k && k < high) {
+			return fmt.Errorf("Search(%q) returned [%d, %d), but the suffix %q at %d disagrees", Query, low, high, Suffix, k)
+		}
+	}
+	return nil
+}
+
+func main() {
+	r := rand.New(rand.NewSource(1))
+	Converter := func(s string) []byte { return []byte(s) }
+	// A range whose bounds don't move on a byte still has to be narrowed: "na" isn't a prefix of "nna"
+	IS := ferret.New([]string{"nab", "anna"}, []string{"nab", "anna"}, []interface{}{0, 1}, Converter)
+	if err := checkSearch(IS, []byte("na")); err != nil {
+		panic(err)
+	}
+	for _, Alphabet := range [][]string{{"a", "b"}, {"a", "b", "c"}, {"a", "é", "b"}} {
+		for Trial := 0; Trial < 20; Trial++ {
+			Words := randomWords(r, 200, 16, Alphabet)
+			Values := make([]interface{}, len(Words))
+			IS := ferret.New(Words, Words, Values, Converter)
+			if len(Alphabet[1]) > 1 {
+				IS = ferret.NewRuneAligned(Words, Words, Values, Converter)
+			}
+			for _, Query := range randomWords(r, 100, 8, Alphabet) {
+				if err := checkSearch(IS, []byte(Query)); err != nil {
+					panic(err)
+				}
+			}
+		}
+	}
+	fmt.Println("Search matches a brute force scan of the suffixes")
+}
@@ -19,16 +19,23 @@
 // Package ferret implements a fast in-memory substring search engine
 package ferret // import "github.com/argusdusty/Ferret"
 
-import "sort"
+import (
+	"context"
+	"sort"
+	"unicode/utf8"
+)
 
 // InvertedSuffix implements the data structure for substring searches
 type InvertedSuffix struct {
-	WordIndex   []int               // WordIndex and SuffixIndex are sorted by Words[WordIndex[i]][SuffixIndex[i]:]
-	SuffixIndex []int               // WordIndex and SuffixIndex are sorted by Words[WordIndex[i]][SuffixIndex[i]:]
-	Words       [][]byte            // Words is the list of words (in []byte form) to perform substring searches over
-	Results     []string            // Results is the string value of the words. Used as a return value
-	Values      []interface{}       // Values is some data mapped to the words. Can be used for sorting, or as a return value
-	Converter   func(string) []byte // Converter converts an inserted word/query to a byte array to search for/with
+	WordIndex   []int                 // WordIndex and SuffixIndex are sorted by Words[WordIndex[i]][SuffixIndex[i]:]
+	SuffixIndex []int                 // WordIndex and SuffixIndex are sorted by Words[WordIndex[i]][SuffixIndex[i]:]
+	Words       [][]byte              // Words is the list of words (in []byte form) to perform substring searches over
+	Results     []string              // Results is the string value of the words. Used as a return value
+	Values      []interface{}         // Values is some data mapped to the words. Can be used for sorting, or as a return value
+	Converter   func(string) []byte   // Converter converts an inserted word/query to a byte array to search for/with
+	RuneAligned bool                  // RuneAligned restricts suffixes to start at UTF-8 rune boundaries
+	Attributes  map[string]*Attribute // Attributes are categorical attributes of the words, indexed for filtering. See AddAttribute
+	Generation  uint64                // Generation counts the changes made by Insert, so that caches can tell when they are stale
 }
 
 // A wrapper type used to sort the three arrays according to sort.sort
@@ -76,6 +83,22 @@ func (SW *sortWrapper) Less(i, j int) bool {
 
 // New creates an inverted suffix from a dictionary of byte arrays, mapping data, and a string->[]byte converter
 func New(Words, Results []string, Data []interface{}, Converter func(string) []byte) *InvertedSuffix {
+	return build(Words, Results, Data, Converter, false)
+}
+
+// NewRuneAligned creates an inverted suffix like New, but only indexes suffixes starting at UTF-8 rune boundaries,
+// so that queries can never match the middle of a multibyte (e.g. CJK) rune.
+// This also makes the index smaller for multibyte text
+func NewRuneAligned(Words, Results []string, Data []interface{}, Converter func(string) []byte) *InvertedSuffix {
+	return build(Words, Results, Data, Converter, true)
+}
+
+// suffixStart returns whether a suffix may start at Word[j]
+func suffixStart(Word []byte, j int, RuneAligned bool) bool {
+	return !RuneAligned || utf8.RuneStart(Word[j])
+}
+
+func build(Words, Results []string, Data []interface{}, Converter func(string) []byte, RuneAligned bool) *InvertedSuffix {
 	CharCount := 0
 	NewWords := make([][]byte, len(Words))
 	for i, Word := range Words {
@@ -87,12 +110,23 @@ func New(Words, Results []string, Data []interface{}, Converter func(string) []b
 	SuffixIndex := make([]int, 0, CharCount)
 	for i, NewWord := range NewWords {
 		for j := 0; j < len(NewWord); j++ {
+			if !suffixStart(NewWord, j, RuneAligned) {
+				continue
+			}
 			WordIndex = append(WordIndex, i)
 			SuffixIndex = append(SuffixIndex, j)
 		}
 	}
 	sort.Sort(&sortWrapper{WordIndex, SuffixIndex, NewWords})
-	Suffixes := &InvertedSuffix{WordIndex, SuffixIndex, NewWords, Results, Data, Converter}
+	Suffixes := &InvertedSuffix{
+		WordIndex:   WordIndex,
+		SuffixIndex: SuffixIndex,
+		Words:       NewWords,
+		Results:     Results,
+		Values:      Data,
+		Converter:   Converter,
+		RuneAligned: RuneAligned,
+	}
 	return Suffixes
 }
 
@@ -100,20 +134,36 @@ func New(Words, Results []string, Data []interface{}, Converter func(string) []b
 // This is pretty slow, because of linear-time insertion into an array,
 // so stick to New when you can
 func (IS *InvertedSuffix) Insert(Word, Result string, Data interface{}) {
-	Query := IS.Converter(Word)
+	IS.insert(IS.Converter(Word), Word, Result, Data)
+}
+
+// insert adds an already converted word to the dictionary
+func (IS *InvertedSuffix) insert(Query []byte, Word, Result string, Data interface{}) {
 	low, high := IS.Search(Query)
 	for k := low; k < high; k++ {
 		if IS.Results[IS.WordIndex[k]] == Word {
 			IS.Values[IS.WordIndex[k]] = Data
+			IS.updateAttributes(IS.WordIndex[k])
+			IS.Generation++
 			return
 		}
 	}
+	IS.add(Query, Result, Data)
+}
+
+// add appends an already converted word to the dictionary, even if it is already present
+func (IS *InvertedSuffix) add(Query []byte, Result string, Data interface{}) {
 	i := len(IS.Words)
 	IS.Words = append(IS.Words, Query)
 	Length := len(Query)
 	IS.Results = append(IS.Results, Result)
 	IS.Values = append(IS.Values, Data)
+	IS.updateAttributes(i)
+	IS.Generation++
 	for j := 0; j < Length; j++ {
+		if !suffixStart(Query, j, IS.RuneAligned) {
+			continue
+		}
 		k, _ := IS.Search(Query[j:])
 		IS.WordIndex = append(IS.WordIndex, 0)
 		copy(IS.WordIndex[k+1:], IS.WordIndex[k:])
@@ -128,13 +178,16 @@ func (IS *InvertedSuffix) Insert(Word, Result string, Data interface{}) {
 // Returns the boundaries (low/high) of sorted suffixes which have the query as a prefix
 // This is a low-level interface. I wouldn't recommend using this yourself
 func (IS *InvertedSuffix) Search(Query []byte) (int, int) {
-	low := 0
-	high := len(IS.WordIndex)
+	return IS.Narrow(0, len(IS.WordIndex), Query, 0)
+}
+
+// Narrow narrows the boundaries (low/high) of sorted suffixes which have Query[:a] as a prefix
+// to the suffixes which have all of Query as a prefix. Search is Narrow from the whole array
+// This is a low-level interface. I wouldn't recommend using this yourself
+func (IS *InvertedSuffix) Narrow(low, high int, Query []byte, a int) (int, int) {
 	n := len(Query)
-	for a := 0; a < n; a++ {
+	for ; a < n; a++ {
 		c := Query[a]
-		oldlow := low
-		oldhigh := high
 		i := low
 		j := high
 		// Raise the lower-bound
@@ -163,11 +216,8 @@ func (IS *InvertedSuffix) Search(Query []byte) (int, int) {
 			break
 		}
 		j = high
-		if low == oldlow && high == oldhigh {
-			// nothing to do here: Word[IS.SuffixIndex[(i + j) >> 1] + a] == c
-			continue
-		}
-		// Lower the upper-bound
+		// Lower the upper-bound. This can't be skipped when neither bound moved:
+		// the lower-bound search doesn't look at every suffix, so some may still have a larger byte than c
 		for i < j {
 			h := (i + j) >> 1
 			Index := IS.WordIndex[h]
@@ -201,19 +251,30 @@ func (IS *InvertedSuffix) Search(Query []byte) (int, int) {
 //     Word: The substring to search for.
 //     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
 func (IS *InvertedSuffix) Query(Word string, ResultsLimit int) ([]string, []interface{}) {
+	Results, Values, _ := IS.QueryContext(context.Background(), Word, ResultsLimit)
+	return Results, Values
+}
+
+// QueryContext is like Query, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) QueryContext(ctx context.Context, Word string, ResultsLimit int) ([]string, []interface{}, error) {
 	Query := IS.Converter(Word)
 	if ResultsLimit == 0 {
-		return []string{}, []interface{}{}
+		return []string{}, []interface{}{}, nil
 	}
 	if ResultsLimit < 0 {
 		ResultsLimit = 0
 	}
 	Results := make([]string, 0, ResultsLimit)
 	Values := make([]interface{}, 0, ResultsLimit)
+	G := newQueryGuard(ctx)
 	low, high := IS.Search(Query)
 	a := 0
 	used := make(map[int]bool, 0)
 	for k := low; k < high; k++ {
+		if !G.step(1) {
+			return Results, Values, G.err
+		}
 		x := IS.WordIndex[k]
 		if _, ok := used[x]; ok {
 			continue
@@ -223,10 +284,10 @@ func (IS *InvertedSuffix) Query(Word string, ResultsLimit int) ([]string, []inte
 		Values = append(Values, IS.Values[x])
 		a++
 		if a == ResultsLimit {
-			return Results, Values
+			return Results, Values, nil
 		}
 	}
-	return Results, Values
+	return Results, Values, nil
 }
 
 // SortedQuery returns the strings which contain the query sorted
@@ -237,9 +298,16 @@ func (IS *InvertedSuffix) Query(Word string, ResultsLimit int) ([]string, []inte
 //     Sorter: Takes (Result, Value, Length, Index (where Query begins in Result)) (string, []byte, int, int)
 //         and produces a value (float64) to sort by (largest first).
 func (IS *InvertedSuffix) SortedQuery(Word string, ResultsLimit int, Sorter func(string, interface{}, int, int) float64) ([]string, []interface{}, []float64) {
+	Results, Values, Scores, _ := IS.SortedQueryContext(context.Background(), Word, ResultsLimit, Sorter)
+	return Results, Values, Scores
+}
+
+// SortedQueryContext is like SortedQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) SortedQueryContext(ctx context.Context, Word string, ResultsLimit int, Sorter func(string, interface{}, int, int) float64) ([]string, []interface{}, []float64, error) {
 	Query := IS.Converter(Word)
 	if ResultsLimit == 0 {
-		return []string{}, []interface{}{}, []float64{}
+		return []string{}, []interface{}{}, []float64{}, nil
 	}
 	if ResultsLimit < 0 {
 		ResultsLimit = 0
@@ -250,10 +318,14 @@ func (IS *InvertedSuffix) SortedQuery(Word string, ResultsLimit int, Sorter func
 	if ResultsLimit == 0 {
 		ResultsLimit = -1
 	}
+	G := newQueryGuard(ctx)
 	low, high := IS.Search(Query)
 	a := 0
 	used := make(map[int]float64, 0)
 	for k := low; k < high; k++ {
+		if !G.step(1) {
+			return Results, Values, Scores, G.err
+		}
 		x := IS.WordIndex[k]
 		w := IS.Results[x]
 		v := IS.Values[x]
@@ -300,7 +372,7 @@ func (IS *InvertedSuffix) SortedQuery(Word string, ResultsLimit int, Sorter func
 			}
 		}
 	}
-	return Results, Values, Scores
+	return Results, Values, Scores, nil
 }
 
 // ErrorCorrectingQuery returns the strings which contain the query
@@ -312,19 +384,30 @@ func (IS *InvertedSuffix) SortedQuery(Word string, ResultsLimit int, Sorter func
 //     ResultsLimit: Limit the results so you don't return your whole dictionary by accident. Set to -1 for no limit
 //     ErrorCorrection: Returns a list of alternate queries
 func (IS *InvertedSuffix) ErrorCorrectingQuery(Word string, ResultsLimit int, ErrorCorrection func([]byte) [][]byte) ([]string, []interface{}) {
+	Results, Values, _ := IS.ErrorCorrectingQueryContext(context.Background(), Word, ResultsLimit, ErrorCorrection)
+	return Results, Values
+}
+
+// ErrorCorrectingQueryContext is like ErrorCorrectingQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) ErrorCorrectingQueryContext(ctx context.Context, Word string, ResultsLimit int, ErrorCorrection func([]byte) [][]byte) ([]string, []interface{}, error) {
 	Query := IS.Converter(Word)
 	if ResultsLimit == 0 {
-		return []string{}, []interface{}{}
+		return []string{}, []interface{}{}, nil
 	}
 	if ResultsLimit < 0 {
 		ResultsLimit = 0
 	}
 	Results := make([]string, 0, ResultsLimit)
 	Values := make([]interface{}, 0, ResultsLimit)
+	G := newQueryGuard(ctx)
 	low, high := IS.Search(Query)
 	a := 0
 	used := make(map[int]bool, 0)
 	for k := low; k < high; k++ {
+		if !G.step(1) {
+			return Results, Values, G.err
+		}
 		x := IS.WordIndex[k]
 		if _, ok := used[x]; ok {
 			continue
@@ -334,13 +417,19 @@ func (IS *InvertedSuffix) ErrorCorrectingQuery(Word string, ResultsLimit int, Er
 		Values = append(Values, IS.Values[x])
 		a++
 		if a == ResultsLimit {
-			return Results, Values
+			return Results, Values, nil
 		}
 	}
 	if a != ResultsLimit {
 		for _, q := range ErrorCorrection(Query) {
+			if !G.step(1) {
+				return Results, Values, G.err
+			}
 			low, high := IS.Search(q)
 			for k := low; k < high; k++ {
+				if !G.step(1) {
+					return Results, Values, G.err
+				}
 				x := IS.WordIndex[k]
 				if _, ok := used[x]; ok {
 					continue
@@ -350,12 +439,12 @@ func (IS *InvertedSuffix) ErrorCorrectingQuery(Word string, ResultsLimit int, Er
 				Values = append(Values, IS.Values[x])
 				a++
 				if a == ResultsLimit {
-					return Results, Values
+					return Results, Values, nil
 				}
 			}
 		}
 	}
-	return Results, Values
+	return Results, Values, nil
 }
 
 // SortedErrorCorrectingQuery returns the strings which contain the query
@@ -369,9 +458,16 @@ func (IS *InvertedSuffix) ErrorCorrectingQuery(Word string, ResultsLimit int, Er
 //     Sorter: Takes (Result, Value, Length, Index (where Query begins in Result))
 //         (string, []byte, int, int), and produces a value (float64) to sort by (largest first).
 func (IS *InvertedSuffix) SortedErrorCorrectingQuery(Word string, ResultsLimit int, ErrorCorrection func([]byte) [][]byte, Sorter func(string, interface{}, int, int) float64) ([]string, []interface{}, []float64) {
+	Results, Values, Scores, _ := IS.SortedErrorCorrectingQueryContext(context.Background(), Word, ResultsLimit, ErrorCorrection, Sorter)
+	return Results, Values, Scores
+}
+
+// SortedErrorCorrectingQueryContext is like SortedErrorCorrectingQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) SortedErrorCorrectingQueryContext(ctx context.Context, Word string, ResultsLimit int, ErrorCorrection func([]byte) [][]byte, Sorter func(string, interface{}, int, int) float64) ([]string, []interface{}, []float64, error) {
 	Query := IS.Converter(Word)
 	if ResultsLimit == 0 {
-		return []string{}, []interface{}{}, []float64{}
+		return []string{}, []interface{}{}, []float64{}, nil
 	}
 	if ResultsLimit < 0 {
 		ResultsLimit = 0
@@ -382,10 +478,14 @@ func (IS *InvertedSuffix) SortedErrorCorrectingQuery(Word string, ResultsLimit i
 	if ResultsLimit == 0 {
 		ResultsLimit = -1
 	}
+	G := newQueryGuard(ctx)
 	low, high := IS.Search(Query)
 	a := 0
 	used := make(map[int]float64, 0)
 	for k := low; k < high; k++ {
+		if !G.step(1) {
+			return Results, Values, Scores, G.err
+		}
 		x := IS.WordIndex[k]
 		w := IS.Results[x]
 		v := IS.Values[x]
@@ -434,8 +534,14 @@ func (IS *InvertedSuffix) SortedErrorCorrectingQuery(Word string, ResultsLimit i
 	}
 	if a == 0 {
 		for _, q := range ErrorCorrection(Query) {
+			if !G.step(1) {
+				return Results, Values, Scores, G.err
+			}
 			low, high := IS.Search(q)
 			for k := low; k < high; k++ {
+				if !G.step(1) {
+					return Results, Values, Scores, G.err
+				}
 				x := IS.WordIndex[k]
 				w := IS.Results[x]
 				v := IS.Values[x]
@@ -484,5 +590,5 @@ func (IS *InvertedSuffix) SortedErrorCorrectingQuery(Word string, ResultsLimit i
 			}
 		}
 	}
-	return Results, Values, Scores
+	return Results, Values, Scores, nil
 }
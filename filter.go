@@ -0,0 +1,114 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"context"
+)
+
+// FilteredQuery returns the strings which contain the query and whose values pass the filter,
+// and their stored values unsorted. The limit applies after filtering
+// Input:
+//     Word: The substring to search for.
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Filter: Takes the Value of a word (interface{}) and returns whether to include it (bool)
+func (IS *InvertedSuffix) FilteredQuery(Word string, ResultsLimit int, Filter func(interface{}) bool) ([]string, []interface{}) {
+	Results, Values, _ := IS.FilteredQueryContext(context.Background(), Word, ResultsLimit, Filter)
+	return Results, Values
+}
+
+// FilteredQueryContext is like FilteredQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) FilteredQueryContext(ctx context.Context, Word string, ResultsLimit int, Filter func(interface{}) bool) ([]string, []interface{}, error) {
+	Query := IS.Converter(Word)
+	if ResultsLimit == 0 {
+		return []string{}, []interface{}{}, nil
+	}
+	if ResultsLimit < 0 {
+		ResultsLimit = 0
+	}
+	Results := make([]string, 0, ResultsLimit)
+	Values := make([]interface{}, 0, ResultsLimit)
+	G := newQueryGuard(ctx)
+	low, high := IS.Search(Query)
+	a := 0
+	used := make(map[int]bool, 0)
+	for k := low; k < high; k++ {
+		if !G.step(1) {
+			return Results, Values, G.err
+		}
+		x := IS.WordIndex[k]
+		if _, ok := used[x]; ok {
+			continue
+		}
+		used[x] = true
+		if !Filter(IS.Values[x]) {
+			continue
+		}
+		Results = append(Results, IS.Results[x])
+		Values = append(Values, IS.Values[x])
+		a++
+		if a == ResultsLimit {
+			return Results, Values, nil
+		}
+	}
+	return Results, Values, nil
+}
+
+// SortedFilteredQuery returns the strings which contain the query and whose values pass the filter sorted
+// The limit applies after filtering, and filtered out words are never scored
+// Input:
+//     Word: The substring to search for.
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Filter: Takes the Value of a word (interface{}) and returns whether to include it (bool)
+//     Sorter: Takes (Result, Value, Length, Index (where Query begins in Result))
+//         (string, interface{}, int, int) and produces a value (float64) to sort by (largest first).
+func (IS *InvertedSuffix) SortedFilteredQuery(Word string, ResultsLimit int, Filter func(interface{}) bool, Sorter func(string, interface{}, int, int) float64) ([]string, []interface{}, []float64) {
+	Results, Values, Scores, _ := IS.SortedFilteredQueryContext(context.Background(), Word, ResultsLimit, Filter, Sorter)
+	return Results, Values, Scores
+}
+
+// SortedFilteredQueryContext is like SortedFilteredQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) SortedFilteredQueryContext(ctx context.Context, Word string, ResultsLimit int, Filter func(interface{}) bool, Sorter func(string, interface{}, int, int) float64) ([]string, []interface{}, []float64, error) {
+	Query := IS.Converter(Word)
+	G := newQueryGuard(ctx)
+	low, high := IS.Search(Query)
+	Top := newTopResults(ResultsLimit)
+	passed := make(map[int]bool, 0)
+	for k := low; k < high; k++ {
+		if !G.step(1) {
+			break
+		}
+		x := IS.WordIndex[k]
+		v := IS.Values[x]
+		ok, seen := passed[x]
+		if !seen {
+			ok = Filter(v)
+			passed[x] = ok
+		}
+		if !ok {
+			continue
+		}
+		w := IS.Results[x]
+		Top.add(x, 0, w, v, Sorter(w, v, len(IS.Words[x]), IS.SuffixIndex[k]))
+	}
+	Results, Values, Scores := Top.split()
+	return Results, Values, Scores, G.err
+}
@@ -0,0 +1,328 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// ErrInvalidCursor is returned when resuming from a malformed cursor
+var ErrInvalidCursor = errors.New("ferret: invalid cursor")
+
+// Cursors are opaque to callers. Unsorted cursors store the next suffix to visit,
+// and sorted cursors store the score and word index of the last result returned.
+// Both also store the cursorState they were taken in
+func encodeCursor(format string, args ...interface{}) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(format, args...)))
+}
+
+func decodeCursor(Cursor string, format string, args ...interface{}) error {
+	Data, err := base64.RawURLEncoding.DecodeString(Cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if _, err := fmt.Sscanf(string(Data), format, args...); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// cursorState identifies the query and the state of the index a cursor was taken in,
+// so a cursor can't be used with another query, or after Insert
+type cursorState struct {
+	Hash       uint64 // Hash is the FNV-1a hash of the converted query
+	Suffixes   int    // Suffixes is len(IS.WordIndex)
+	Generation uint64 // Generation is IS.Generation
+}
+
+func (IS *InvertedSuffix) cursorState(Query []byte) cursorState {
+	h := fnv.New64a()
+	h.Write(Query)
+	return cursorState{h.Sum64(), len(IS.WordIndex), IS.Generation}
+}
+
+// firstOccurrence returns whether the suffix at k is the first suffix of its word in the range of Query
+// Suffixes of the same word are distinct, so this is the smallest suffix of the word starting with Query.
+// This lets iterators skip duplicate words without remembering the words they've already seen
+func (IS *InvertedSuffix) firstOccurrence(k int, Query []byte) bool {
+	Word := IS.Words[IS.WordIndex[k]]
+	Suffix := Word[IS.SuffixIndex[k]:]
+	for i := 0; i+len(Query) <= len(Word); i++ {
+		j := bytes.Index(Word[i:], Query)
+		if j < 0 {
+			break
+		}
+		i += j
+		if !suffixStart(Word, i, IS.RuneAligned) {
+			continue
+		}
+		if bytes.Compare(Word[i:], Suffix) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Iterator iterates over the strings which contain a query, unsorted, in the same order as Query
+// Insert invalidates iterators and cursors
+type Iterator struct {
+	IS    *InvertedSuffix
+	Query []byte
+	k     int
+	high  int
+	x     int
+	state cursorState
+}
+
+// Iterate returns an iterator over the strings which contain the query
+// Use like:
+//     for it := IS.Iterate(Word); it.Next(); {
+//         fmt.Println(it.Result(), it.Value())
+//     }
+func (IS *InvertedSuffix) Iterate(Word string) *Iterator {
+	Query := IS.Converter(Word)
+	low, high := IS.Search(Query)
+	return &Iterator{IS, Query, low, high, -1, IS.cursorState(Query)}
+}
+
+// IterateFrom returns an iterator over the strings which contain the query,
+// resuming after the result where Cursor was taken (see Iterator.Cursor)
+// Returns ErrInvalidCursor if Cursor was taken for another query, or before an Insert
+func (IS *InvertedSuffix) IterateFrom(Word string, Cursor string) (*Iterator, error) {
+	it := IS.Iterate(Word)
+	if Cursor == "" {
+		return it, nil
+	}
+	var k int
+	var State cursorState
+	if err := decodeCursor(Cursor, "u%d,%x,%d,%d", &k, &State.Hash, &State.Suffixes, &State.Generation); err != nil {
+		return nil, err
+	}
+	if State != it.state || k > it.high {
+		return nil, ErrInvalidCursor
+	}
+	if k > it.k {
+		it.k = k
+	}
+	return it, nil
+}
+
+// Next advances to the next result, returning false when there are none left
+func (it *Iterator) Next() bool {
+	return it.next(nil)
+}
+
+// next is Next, also returning false when G stops it. The iterator can then be resumed from its Cursor
+func (it *Iterator) next(G *queryGuard) bool {
+	IS := it.IS
+	for ; it.k < it.high; it.k++ {
+		if !G.step(1) {
+			it.x = -1
+			return false
+		}
+		if IS.firstOccurrence(it.k, it.Query) {
+			it.x = IS.WordIndex[it.k]
+			it.k++
+			return true
+		}
+	}
+	it.x = -1
+	return false
+}
+
+// Result returns the string value of the current result
+func (it *Iterator) Result() string {
+	return it.IS.Results[it.x]
+}
+
+// Value returns the stored value of the current result
+func (it *Iterator) Value() interface{} {
+	return it.IS.Values[it.x]
+}
+
+// Cursor returns an opaque cursor to resume iterating after the current result with IterateFrom
+// Returns "" once the iterator is exhausted
+func (it *Iterator) Cursor() string {
+	if it.k >= it.high && it.x < 0 {
+		return ""
+	}
+	return encodeCursor("u%d,%x,%d,%d", it.k, it.state.Hash, it.state.Suffixes, it.state.Generation)
+}
+
+// QueryPage returns a page of the strings which contain the query, and their stored values unsorted,
+// in the same order as Query. Also returns the cursor of the next page, or "" if this is the last page
+// Returns ErrInvalidCursor if Cursor was taken for another query, or before an Insert
+// Input:
+//     Word: The substring to search for.
+//     Cursor: The cursor returned with the previous page. Set to "" for the first page
+//     PageSize: The number of results per page. Set to -1 for no limit (a single page of every result)
+func (IS *InvertedSuffix) QueryPage(Word string, Cursor string, PageSize int) ([]string, []interface{}, string, error) {
+	return IS.QueryPageContext(context.Background(), Word, Cursor, PageSize)
+}
+
+// QueryPageContext is like QueryPage, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far, the cursor to resume after them, and why it stopped
+func (IS *InvertedSuffix) QueryPageContext(ctx context.Context, Word string, Cursor string, PageSize int) ([]string, []interface{}, string, error) {
+	if PageSize == 0 {
+		return []string{}, []interface{}{}, "", nil
+	}
+	it, err := IS.IterateFrom(Word, Cursor)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	Size := PageSize
+	if Size < 0 {
+		Size = 0
+	}
+	Results := make([]string, 0, Size)
+	Values := make([]interface{}, 0, Size)
+	G := newQueryGuard(ctx)
+	for (PageSize < 0 || len(Results) < PageSize) && it.next(G) {
+		Results = append(Results, it.Result())
+		Values = append(Values, it.Value())
+	}
+	if G.err == nil && (PageSize < 0 || len(Results) < PageSize || !it.more(G)) {
+		return Results, Values, "", nil
+	}
+	return Results, Values, it.Cursor(), G.err
+}
+
+// more returns whether there are results after the current one, or true if G stops it before finding out
+func (it *Iterator) more(G *queryGuard) bool {
+	for k := it.k; k < it.high; k++ {
+		if !G.step(1) {
+			return true
+		}
+		if it.IS.firstOccurrence(k, it.Query) {
+			return true
+		}
+	}
+	return false
+}
+
+// SortedQueryPage returns a page of the strings which contain the query sorted,
+// with ties broken by word index. Also returns the cursor of the next page, or "" if this is the last page
+// The cursor only stores where the previous page ended, so every page still searches and scores
+// every match (costing as much as SortedQuery), but only the current page is kept
+// Returns ErrInvalidCursor if Cursor was taken for another query, or before an Insert
+// Input:
+//     Word: The substring to search for.
+//     Cursor: The cursor returned with the previous page. Set to "" for the first page
+//     PageSize: The number of results per page. Set to -1 for no limit (a single page of every result)
+//     Sorter: Takes (Result, Value, Length, Index (where Query begins in Result))
+//         (string, interface{}, int, int) and produces a value (float64) to sort by (largest first).
+func (IS *InvertedSuffix) SortedQueryPage(Word string, Cursor string, PageSize int, Sorter func(string, interface{}, int, int) float64) ([]string, []interface{}, []float64, string, error) {
+	return IS.SortedQueryPageContext(context.Background(), Word, Cursor, PageSize, Sorter)
+}
+
+// SortedQueryPageContext is like SortedQueryPage, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the best page of the matches scored so far and why it stopped.
+// That page has no next cursor, since matches which weren't scored could belong before its end
+func (IS *InvertedSuffix) SortedQueryPageContext(ctx context.Context, Word string, Cursor string, PageSize int, Sorter func(string, interface{}, int, int) float64) ([]string, []interface{}, []float64, string, error) {
+	if PageSize == 0 {
+		return []string{}, []interface{}{}, []float64{}, "", nil
+	}
+	Query := IS.Converter(Word)
+	Current := IS.cursorState(Query)
+	After := math.Inf(1)
+	AfterX := -1
+	if Cursor != "" {
+		var Bits uint64
+		var State cursorState
+		if err := decodeCursor(Cursor, "s%x,%d,%x,%d,%d", &Bits, &AfterX, &State.Hash, &State.Suffixes, &State.Generation); err != nil {
+			return nil, nil, nil, "", err
+		}
+		if State != Current {
+			return nil, nil, nil, "", ErrInvalidCursor
+		}
+		After = math.Float64frombits(Bits)
+	}
+	G := newQueryGuard(ctx)
+	low, high := IS.Search(Query)
+	Best := make(map[int]float64, 0)
+	for k := low; k < high; k++ {
+		if !G.step(1) {
+			break
+		}
+		x := IS.WordIndex[k]
+		s := Sorter(IS.Results[x], IS.Values[x], len(IS.Words[x]), IS.SuffixIndex[k])
+		if ps, ok := Best[x]; !ok || s > ps {
+			Best[x] = s
+		}
+	}
+	// before returns whether (s, x) comes before (t, y)
+	before := func(s float64, x int, t float64, y int) bool {
+		return s > t || s == t && x < y
+	}
+	if PageSize < 0 {
+		PageSize = len(Best)
+	}
+	Page := make([]int, 0, PageSize+1)
+	Scores := make([]float64, 0, PageSize+1)
+	for x, s := range Best {
+		if !before(After, AfterX, s, x) {
+			continue
+		}
+		i := 0
+		j := len(Page)
+		for i < j {
+			h := (i + j) >> 1
+			if before(Scores[h], Page[h], s, x) {
+				i = h + 1
+			} else {
+				j = h
+			}
+		}
+		if i > PageSize {
+			continue
+		}
+		// Keep one extra result, to know whether there is a next page
+		Page = append(Page, 0)
+		copy(Page[i+1:], Page[i:])
+		Page[i] = x
+		Scores = append(Scores, 0)
+		copy(Scores[i+1:], Scores[i:])
+		Scores[i] = s
+		if len(Page) > PageSize+1 {
+			Page = Page[:PageSize+1]
+			Scores = Scores[:PageSize+1]
+		}
+	}
+	Next := ""
+	if len(Page) > PageSize {
+		Page = Page[:PageSize]
+		Scores = Scores[:PageSize]
+		if G.err == nil {
+			Next = encodeCursor("s%x,%d,%x,%d,%d", math.Float64bits(Scores[PageSize-1]), Page[PageSize-1], Current.Hash, Current.Suffixes, Current.Generation)
+		}
+	}
+	Results := make([]string, len(Page))
+	Values := make([]interface{}, len(Page))
+	for i, x := range Page {
+		Results[i] = IS.Results[x]
+		Values[i] = IS.Values[x]
+	}
+	return Results, Values, Scores, Next, G.err
+}
@@ -0,0 +1,203 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"context"
+	"sort"
+)
+
+// Match describes how a word matched a query, for scoring by a MatchSorter
+type Match struct {
+	WordID    int         // WordID is the index of the word (or the document ID, for DocumentIndex)
+	Word      []byte      // Word is the converted word
+	Result    string      // Result is the string value of the word
+	Value     interface{} // Value is the stored value of the word
+	Length    int         // Length is len(Word)
+	Positions []int       // Positions are every index where Corrected begins in Word, in increasing order
+	Query     []byte      // Query is the converted query
+	Corrected []byte      // Corrected is the query which matched: Query, or an alternate query from ErrorCorrection
+	Distance  int         // Distance is the number of corrections from Query to Corrected (0 or 1)
+	Field     string      // Field is the name of the matched field, for DocumentIndex. "" otherwise
+}
+
+// MatchSorter scores a matched word, with the full context of the match
+// Each word is scored once per query (or per alternate query) which matched it
+type MatchSorter interface {
+	Score(M *Match) float64
+}
+
+// MatchSorterFunc adapts a function to a MatchSorter
+type MatchSorterFunc func(M *Match) float64
+
+// Score returns f(M)
+func (f MatchSorterFunc) Score(M *Match) float64 {
+	return f(M)
+}
+
+// matchPositions groups the suffixes which have the query as a prefix by word,
+// returning the words in the order they were first found, and the sorted positions of the query in each
+// If G stops it, no words are returned, as their positions may be incomplete
+func (IS *InvertedSuffix) matchPositions(G *queryGuard, Query []byte) ([]int, map[int][]int) {
+	low, high := IS.Search(Query)
+	Order := make([]int, 0)
+	Positions := make(map[int][]int, 0)
+	for k := low; k < high; k++ {
+		if !G.step(1) {
+			return nil, nil
+		}
+		x := IS.WordIndex[k]
+		if _, ok := Positions[x]; !ok {
+			Order = append(Order, x)
+		}
+		Positions[x] = append(Positions[x], IS.SuffixIndex[k])
+	}
+	for _, x := range Order {
+		sort.Ints(Positions[x])
+	}
+	return Order, Positions
+}
+
+// newMatch returns the Match of word x
+func (IS *InvertedSuffix) newMatch(x int, Positions []int, Query, Corrected []byte, Distance int) *Match {
+	return &Match{
+		WordID:    x,
+		Word:      IS.Words[x],
+		Result:    IS.Results[x],
+		Value:     IS.Values[x],
+		Length:    len(IS.Words[x]),
+		Positions: Positions,
+		Query:     Query,
+		Corrected: Corrected,
+		Distance:  Distance,
+	}
+}
+
+// scoreMatches scores each word containing Corrected with Sorter, adding them to Top, unless G stops it
+func (IS *InvertedSuffix) scoreMatches(G *queryGuard, Top *topResults, Query, Corrected []byte, Distance int, Sorter MatchSorter) {
+	Order, Positions := IS.matchPositions(G, Corrected)
+	for _, x := range Order {
+		M := IS.newMatch(x, Positions[x], Query, Corrected, Distance)
+		Top.add(x, 0, M.Result, M.Value, Sorter.Score(M))
+	}
+}
+
+// MatchSortedQuery returns the strings which contain the query sorted
+// Like SortedQuery, but each word is scored once, with every position of the query in it
+// Input:
+//     Word: The substring to search for.
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Sorter: Scores each match (*Match), producing a value (float64) to sort by (largest first).
+func (IS *InvertedSuffix) MatchSortedQuery(Word string, ResultsLimit int, Sorter MatchSorter) ([]string, []interface{}, []float64) {
+	Results, Values, Scores, _ := IS.MatchSortedQueryContext(context.Background(), Word, ResultsLimit, Sorter)
+	return Results, Values, Scores
+}
+
+// MatchSortedQueryContext is like MatchSortedQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning why it stopped. Words are only scored once all of their positions
+// are found, so it returns no results if it stops
+func (IS *InvertedSuffix) MatchSortedQueryContext(ctx context.Context, Word string, ResultsLimit int, Sorter MatchSorter) ([]string, []interface{}, []float64, error) {
+	Query := IS.Converter(Word)
+	G := newQueryGuard(ctx)
+	Top := newTopResults(ResultsLimit)
+	IS.scoreMatches(G, Top, Query, Query, 0, Sorter)
+	Results, Values, Scores := Top.split()
+	return Results, Values, Scores, G.err
+}
+
+// MatchSortedErrorCorrectingQuery returns the strings which contain the query sorted
+// Will search for all substrings defined by ErrorCorrection
+// if no results are found on the initial query, with Match.Corrected set to the alternate query
+// Input:
+//     Word: The substring to search for.
+//     ResultsLimit: Limit the results so you don't return your whole dictionary by accident. Set to -1 for no limit
+//     ErrorCorrection: Returns a list of alternate queries
+//     Sorter: Scores each match (*Match), producing a value (float64) to sort by (largest first).
+func (IS *InvertedSuffix) MatchSortedErrorCorrectingQuery(Word string, ResultsLimit int, ErrorCorrection func([]byte) [][]byte, Sorter MatchSorter) ([]string, []interface{}, []float64) {
+	Results, Values, Scores, _ := IS.MatchSortedErrorCorrectingQueryContext(context.Background(), Word, ResultsLimit, ErrorCorrection, Sorter)
+	return Results, Values, Scores
+}
+
+// MatchSortedErrorCorrectingQueryContext is like MatchSortedErrorCorrectingQuery, but stops early when ctx is done
+// or its work budget (see WithWorkBudget) is exceeded, returning the results of the queries finished so far and why it stopped
+func (IS *InvertedSuffix) MatchSortedErrorCorrectingQueryContext(ctx context.Context, Word string, ResultsLimit int, ErrorCorrection func([]byte) [][]byte, Sorter MatchSorter) ([]string, []interface{}, []float64, error) {
+	Query := IS.Converter(Word)
+	G := newQueryGuard(ctx)
+	Top := newTopResults(ResultsLimit)
+	IS.scoreMatches(G, Top, Query, Query, 0, Sorter)
+	if len(Top.Best) == 0 {
+		for _, q := range ErrorCorrection(Query) {
+			if !G.step(1) {
+				break
+			}
+			IS.scoreMatches(G, Top, Query, q, 1, Sorter)
+		}
+	}
+	Results, Values, Scores := Top.split()
+	return Results, Values, Scores, G.err
+}
+
+// MatchSortedQuery returns the documents with a field containing the query sorted,
+// and which field matched. Each document is scored once per matching field, keeping its best score
+// Input:
+//     Word: The substring to search for.
+//     Fields: The names of the fields to search. Set to nil to search all fields
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Sorter: Scores each match (*Match), producing a value (float64) to sort by (largest first).
+//         WordID is the document ID, and Word the converted field value
+func (DI *DocumentIndex) MatchSortedQuery(Word string, Fields []string, ResultsLimit int, Sorter MatchSorter) ([]string, []interface{}, []float64, []string) {
+	Results, Values, Scores, Matched, _ := DI.MatchSortedQueryContext(context.Background(), Word, Fields, ResultsLimit, Sorter)
+	return Results, Values, Scores, Matched
+}
+
+// MatchSortedQueryContext is like MatchSortedQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results of the fields finished so far and why it stopped
+func (DI *DocumentIndex) MatchSortedQueryContext(ctx context.Context, Word string, Fields []string, ResultsLimit int, Sorter MatchSorter) ([]string, []interface{}, []float64, []string, error) {
+	G := newQueryGuard(ctx)
+	Top := newTopResults(ResultsLimit)
+	for _, f := range DI.fieldIndexes(Fields) {
+		if !G.step(1) {
+			break
+		}
+		IS := DI.Indexes[f]
+		Query := IS.Converter(Word)
+		Order, Positions := IS.matchPositions(G, Query)
+		for _, x := range Order {
+			ID := IS.Values[x].(int)
+			M := &Match{
+				WordID:    ID,
+				Word:      IS.Words[x],
+				Result:    DI.Results[ID],
+				Value:     DI.Values[ID],
+				Length:    len(IS.Words[x]),
+				Positions: Positions[x],
+				Query:     Query,
+				Corrected: Query,
+				Field:     DI.Fields[f],
+			}
+			Top.add(ID, f, M.Result, M.Value, Sorter.Score(M))
+		}
+	}
+	Results, Values, Scores := Top.split()
+	Matched := make([]string, len(Results))
+	for i, r := range Top.results() {
+		Matched[i] = DI.Fields[r.Aux]
+	}
+	return Results, Values, Scores, Matched, G.err
+}
@@ -0,0 +1,142 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+)
+
+// Terms splits Words on whitespace and converts each term, dropping terms which convert to nothing
+func (IS *InvertedSuffix) Terms(Words string) [][]byte {
+	Terms := make([][]byte, 0)
+	for _, Word := range strings.Fields(Words) {
+		if Term := IS.Converter(Word); len(Term) > 0 {
+			Terms = append(Terms, Term)
+		}
+	}
+	return Terms
+}
+
+// multiSearch calls f with the word index of each word containing all of the terms, in any order,
+// and the position of the first occurrence of each term in the word, until f returns false or G stops the search.
+// The distinct words of each term's range are intersected, starting with the rarest term, so words are visited
+// in increasing order of word index
+func (IS *InvertedSuffix) multiSearch(G *queryGuard, Terms [][]byte, f func(x int, Positions []int) bool) {
+	if len(Terms) == 0 {
+		Terms = [][]byte{{}}
+	}
+	Ranges := make([][2]int, len(Terms))
+	for t, Term := range Terms {
+		low, high := IS.Search(Term)
+		Ranges[t] = [2]int{low, high}
+	}
+	Order := make([]int, len(Terms))
+	for t := range Order {
+		Order[t] = t
+	}
+	sort.Slice(Order, func(i, j int) bool {
+		a, b := Ranges[Order[i]], Ranges[Order[j]]
+		return a[1]-a[0] < b[1]-b[0]
+	})
+	var Set []int
+	for i, t := range Order {
+		Words := IS.rangeWords(G, Ranges[t][0], Ranges[t][1])
+		if G.err != nil {
+			return
+		}
+		if i == 0 {
+			Set = Words
+		} else {
+			Set = intersectSet(Set, Words)
+		}
+		if len(Set) == 0 {
+			return
+		}
+	}
+	for _, x := range Set {
+		Positions := make([]int, len(Terms))
+		for t, Term := range Terms {
+			Positions[t] = bytes.Index(IS.Words[x], Term)
+		}
+		if !f(x, Positions) {
+			return
+		}
+	}
+}
+
+// MultiQuery returns the strings which contain all of the whitespace separated terms of the query,
+// in any order, and their stored values unsorted (in order of insertion)
+// Input:
+//     Words: The substrings to search for, separated by whitespace.
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+func (IS *InvertedSuffix) MultiQuery(Words string, ResultsLimit int) ([]string, []interface{}) {
+	Results, Values, _ := IS.MultiQueryContext(context.Background(), Words, ResultsLimit)
+	return Results, Values
+}
+
+// MultiQueryContext is like MultiQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) MultiQueryContext(ctx context.Context, Words string, ResultsLimit int) ([]string, []interface{}, error) {
+	if ResultsLimit == 0 {
+		return []string{}, []interface{}{}, nil
+	}
+	if ResultsLimit < 0 {
+		ResultsLimit = 0
+	}
+	Results := make([]string, 0, ResultsLimit)
+	Values := make([]interface{}, 0, ResultsLimit)
+	G := newQueryGuard(ctx)
+	a := 0
+	IS.multiSearch(G, IS.Terms(Words), func(x int, Positions []int) bool {
+		Results = append(Results, IS.Results[x])
+		Values = append(Values, IS.Values[x])
+		a++
+		return a != ResultsLimit
+	})
+	return Results, Values, G.err
+}
+
+// SortedMultiQuery returns the strings which contain all of the whitespace separated terms of the query sorted
+// Input:
+//     Words: The substrings to search for, separated by whitespace.
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Sorter: Takes (Result, Value, Length, Positions (where each term first begins in Result, in query order))
+//         (string, interface{}, int, []int) and produces a value (float64) to sort by (largest first).
+func (IS *InvertedSuffix) SortedMultiQuery(Words string, ResultsLimit int, Sorter func(string, interface{}, int, []int) float64) ([]string, []interface{}, []float64) {
+	Results, Values, Scores, _ := IS.SortedMultiQueryContext(context.Background(), Words, ResultsLimit, Sorter)
+	return Results, Values, Scores
+}
+
+// SortedMultiQueryContext is like SortedMultiQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) SortedMultiQueryContext(ctx context.Context, Words string, ResultsLimit int, Sorter func(string, interface{}, int, []int) float64) ([]string, []interface{}, []float64, error) {
+	G := newQueryGuard(ctx)
+	Top := newTopResults(ResultsLimit)
+	IS.multiSearch(G, IS.Terms(Words), func(x int, Positions []int) bool {
+		w := IS.Results[x]
+		v := IS.Values[x]
+		Top.add(x, 0, w, v, Sorter(w, v, len(IS.Words[x]), Positions))
+		return true
+	})
+	Results, Values, Scores := Top.split()
+	return Results, Values, Scores, G.err
+}
@@ -0,0 +1,111 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"unicode/utf8"
+)
+
+// Similarity is a measure of the overlap of the q-grams of two words, for NGramQuery
+type Similarity int
+
+const (
+	Jaccard Similarity = iota // Jaccard is the shared q-grams over the q-grams in either word
+	Dice                      // Dice is twice the shared q-grams over the sum of the q-grams in each word
+)
+
+// grams returns the distinct substrings of Word which are Q characters (bytes, or runes for RuneAligned indexes) long
+// Substrings spanning a FormSeparator are skipped
+func (IS *InvertedSuffix) grams(Word []byte, Q int) map[string]bool {
+	Starts := make([]int, 0, len(Word)+1)
+	for j := 0; j < len(Word); j++ {
+		if !IS.RuneAligned || utf8.RuneStart(Word[j]) {
+			Starts = append(Starts, j)
+		}
+	}
+	Starts = append(Starts, len(Word))
+	Grams := make(map[string]bool)
+	for i := 0; i+Q < len(Starts); i++ {
+		Gram := Word[Starts[i]:Starts[i+Q]]
+		if bytes.IndexByte(Gram, FormSeparator) < 0 {
+			Grams[string(Gram)] = true
+		}
+	}
+	return Grams
+}
+
+// NGramQuery returns the strings which share enough of their q-grams (substrings of Q characters) with the query,
+// sorted by their similarity to the query (largest first), and their similarities.
+// This is more forgiving than error correction for long strings, and ignores the order of the q-grams.
+// Each q-gram of the query is searched for, counting the words containing it
+// Input:
+//     Word: The string to search for.
+//     Q: The length of the q-grams, e.g. 3 for trigrams
+//     Threshold: The lowest similarity (0 to 1) to return
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Measure: How to measure similarity, Jaccard or Dice
+func (IS *InvertedSuffix) NGramQuery(Word string, Q int, Threshold float64, ResultsLimit int, Measure Similarity) ([]string, []interface{}, []float64) {
+	Results, Values, Scores, _ := IS.NGramQueryContext(context.Background(), Word, Q, Threshold, ResultsLimit, Measure)
+	return Results, Values, Scores
+}
+
+// NGramQueryContext is like NGramQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped.
+// It returns no results if it stops while counting the shared q-grams, as the similarities would be too low
+func (IS *InvertedSuffix) NGramQueryContext(ctx context.Context, Word string, Q int, Threshold float64, ResultsLimit int, Measure Similarity) ([]string, []interface{}, []float64, error) {
+	Top := newTopResults(ResultsLimit)
+	if Q <= 0 {
+		Results, Values, Scores := Top.split()
+		return Results, Values, Scores, nil
+	}
+	G := newQueryGuard(ctx)
+	QueryGrams := IS.grams(IS.Converter(Word), Q)
+	Shared := make(map[int]int)
+	for Gram := range QueryGrams {
+		low, high := IS.Search([]byte(Gram))
+		IS.distinctWords(G, low, high, func(x int) { Shared[x]++ })
+	}
+	Candidates := make([]int, 0, len(Shared))
+	for x := range Shared {
+		Candidates = append(Candidates, x)
+	}
+	// Equal scores keep the last added first, so adding in decreasing order breaks ties by word index
+	sort.Sort(sort.Reverse(sort.IntSlice(Candidates)))
+	for _, x := range Candidates {
+		if !G.step(1) {
+			break
+		}
+		s := Shared[x]
+		n := len(IS.grams(IS.Words[x], Q))
+		var Score float64
+		if Measure == Dice {
+			Score = 2 * float64(s) / float64(len(QueryGrams)+n)
+		} else {
+			Score = float64(s) / float64(len(QueryGrams)+n-s)
+		}
+		if Score >= Threshold {
+			Top.add(x, 0, IS.Results[x], IS.Values[x], Score)
+		}
+	}
+	Results, Values, Scores := Top.split()
+	return Results, Values, Scores, G.err
+}
@@ -0,0 +1,173 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+)
+
+// ResultOrder is the order of the results of OrderedQuery
+type ResultOrder int
+
+const (
+	SuffixOrder       ResultOrder = iota // SuffixOrder is the order of Query: by the matched suffix. Changes with Insert
+	InsertionOrder                       // InsertionOrder is by word index, so earlier words come first
+	AlphabeticalOrder                    // AlphabeticalOrder is by string value (Result), then word index
+)
+
+// wordHeap is a heap of word indexes with the last word (in order) on top
+type wordHeap struct {
+	Words  []int
+	before func(x, y int) bool
+}
+
+func (H *wordHeap) Len() int           { return len(H.Words) }
+func (H *wordHeap) Less(i, j int) bool { return H.before(H.Words[j], H.Words[i]) }
+func (H *wordHeap) Swap(i, j int)      { H.Words[i], H.Words[j] = H.Words[j], H.Words[i] }
+func (H *wordHeap) Push(x interface{}) { H.Words = append(H.Words, x.(int)) }
+func (H *wordHeap) Pop() interface{} {
+	x := H.Words[len(H.Words)-1]
+	H.Words = H.Words[:len(H.Words)-1]
+	return x
+}
+
+// orderedWords returns the distinct words with a suffix in any of the ranges, in the given order,
+// skipping (and then marking) the words in used. With a limit, only the first Limit words are kept
+// in a heap as the ranges are scanned. Set Limit to -1 for no limit.
+// If G stops the scan, the words found so far are returned, in order
+func (IS *InvertedSuffix) orderedWords(G *queryGuard, Ranges [][2]int, used map[int]bool, Limit int, Order ResultOrder) []int {
+	before := func(x, y int) bool {
+		if Order == AlphabeticalOrder && IS.Results[x] != IS.Results[y] {
+			return IS.Results[x] < IS.Results[y]
+		}
+		return x < y
+	}
+	H := &wordHeap{make([]int, 0), before}
+Scan:
+	for _, Range := range Ranges {
+		for k := Range[0]; k < Range[1]; k++ {
+			if !G.step(1) {
+				break Scan
+			}
+			x := IS.WordIndex[k]
+			if _, ok := used[x]; ok {
+				continue
+			}
+			used[x] = true
+			if Order == SuffixOrder || Limit < 0 {
+				H.Words = append(H.Words, x)
+				if len(H.Words) == Limit {
+					return H.Words
+				}
+			} else if H.Len() < Limit {
+				heap.Push(H, x)
+			} else if Limit > 0 && before(x, H.Words[0]) {
+				H.Words[0] = x
+				heap.Fix(H, 0)
+			}
+		}
+	}
+	if Order != SuffixOrder {
+		sort.Slice(H.Words, func(i, j int) bool { return before(H.Words[i], H.Words[j]) })
+	}
+	return H.Words
+}
+
+// OrderedQuery returns the strings which contain the query, and their stored values, in the given order
+// Unlike SortedQuery, this doesn't score the results, so the order is stable across queries and inserts
+// Input:
+//     Word: The substring to search for.
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Order: The order of the results, e.g. InsertionOrder
+func (IS *InvertedSuffix) OrderedQuery(Word string, ResultsLimit int, Order ResultOrder) ([]string, []interface{}) {
+	Results, Values, _ := IS.OrderedQueryContext(context.Background(), Word, ResultsLimit, Order)
+	return Results, Values
+}
+
+// OrderedQueryContext is like OrderedQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) OrderedQueryContext(ctx context.Context, Word string, ResultsLimit int, Order ResultOrder) ([]string, []interface{}, error) {
+	if ResultsLimit == 0 {
+		return []string{}, []interface{}{}, nil
+	}
+	Query := IS.Converter(Word)
+	G := newQueryGuard(ctx)
+	low, high := IS.Search(Query)
+	Words := IS.orderedWords(G, [][2]int{{low, high}}, make(map[int]bool, 0), ResultsLimit, Order)
+	Results, Values := IS.wordResults(Words)
+	return Results, Values, G.err
+}
+
+// OrderedErrorCorrectingQuery returns the strings which contain the query, and their stored values, in the given order
+// Will search for all substrings defined by ErrorCorrection if there are fewer than ResultsLimit results
+// on the initial query. The results of the alternate queries are ordered after those of the initial query
+// Input:
+//     Word: The substring to search for.
+//     ResultsLimit: Limit the results so you don't return your whole dictionary by accident. Set to -1 for no limit
+//     ErrorCorrection: Returns a list of alternate queries
+//     Order: The order of the results, e.g. InsertionOrder
+func (IS *InvertedSuffix) OrderedErrorCorrectingQuery(Word string, ResultsLimit int, ErrorCorrection func([]byte) [][]byte, Order ResultOrder) ([]string, []interface{}) {
+	Results, Values, _ := IS.OrderedErrorCorrectingQueryContext(context.Background(), Word, ResultsLimit, ErrorCorrection, Order)
+	return Results, Values
+}
+
+// OrderedErrorCorrectingQueryContext is like OrderedErrorCorrectingQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) OrderedErrorCorrectingQueryContext(ctx context.Context, Word string, ResultsLimit int, ErrorCorrection func([]byte) [][]byte, Order ResultOrder) ([]string, []interface{}, error) {
+	if ResultsLimit == 0 {
+		return []string{}, []interface{}{}, nil
+	}
+	Query := IS.Converter(Word)
+	G := newQueryGuard(ctx)
+	low, high := IS.Search(Query)
+	used := make(map[int]bool, 0)
+	Words := IS.orderedWords(G, [][2]int{{low, high}}, used, ResultsLimit, Order)
+	if len(Words) != ResultsLimit && G.err == nil {
+		Ranges := make([][2]int, 0)
+		for _, q := range ErrorCorrection(Query) {
+			if !G.step(1) {
+				break
+			}
+			low, high := IS.Search(q)
+			if low < high {
+				Ranges = append(Ranges, [2]int{low, high})
+			}
+		}
+		Limit := ResultsLimit
+		if Limit >= 0 {
+			Limit -= len(Words)
+		}
+		Words = append(Words, IS.orderedWords(G, Ranges, used, Limit, Order)...)
+	}
+	Results, Values := IS.wordResults(Words)
+	return Results, Values, G.err
+}
+
+// wordResults returns the string values and stored values of the given words
+func (IS *InvertedSuffix) wordResults(Words []int) ([]string, []interface{}) {
+	Results := make([]string, len(Words))
+	Values := make([]interface{}, len(Words))
+	for i, x := range Words {
+		Results[i] = IS.Results[x]
+		Values[i] = IS.Values[x]
+	}
+	return Results, Values
+}
@@ -0,0 +1,117 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"context"
+	"regexp"
+	"regexp/syntax"
+)
+
+// requiredLiteral returns the longest literal which must appear in every match of re,
+// or nil if there isn't one
+func requiredLiteral(re *syntax.Regexp) []byte {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return nil
+		}
+		return []byte(string(re.Rune))
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiteral(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiteral(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		// Adjacent literals join into one longer literal
+		var Longest, Run []byte
+		for _, Sub := range re.Sub {
+			if Sub.Op == syntax.OpLiteral && Sub.Flags&syntax.FoldCase == 0 {
+				Run = append(Run, string(Sub.Rune)...)
+				if len(Run) > len(Longest) {
+					Longest = Run
+				}
+				continue
+			}
+			Run = nil
+			if Literal := requiredLiteral(Sub); len(Literal) > len(Longest) {
+				Longest = Literal
+			}
+		}
+		return Longest
+	}
+	return nil
+}
+
+// RegexpQuery returns the strings whose converted words match the regular expression, their stored values,
+// and the spans ([start, end) pairs, in the converted word) of every match, ordered by word index.
+// Candidates are narrowed with the longest literal required by the pattern, then checked against the
+// whole pattern. Patterns without a required literal (e.g. case-insensitive ones) check every word.
+// Returns an error if the pattern doesn't compile
+// Input:
+//     Pattern: The regular expression to search for, in Go regexp syntax. It is matched against the converted
+//         words, so should be written in their form (e.g. lowercase for UnicodeToLowerASCII)
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+func (IS *InvertedSuffix) RegexpQuery(Pattern string, ResultsLimit int) ([]string, []interface{}, [][][]int, error) {
+	return IS.RegexpQueryContext(context.Background(), Pattern, ResultsLimit)
+}
+
+// RegexpQueryContext is like RegexpQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) RegexpQueryContext(ctx context.Context, Pattern string, ResultsLimit int) ([]string, []interface{}, [][][]int, error) {
+	Parsed, err := syntax.Parse(Pattern, syntax.Perl)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	Regexp, err := regexp.Compile(Pattern)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if ResultsLimit == 0 {
+		return []string{}, []interface{}{}, [][][]int{}, nil
+	}
+	G := newQueryGuard(ctx)
+	var Candidates []int
+	if Literal := requiredLiteral(Parsed.Simplify()); len(Literal) > 0 {
+		low, high := IS.Search(Literal)
+		Candidates = IS.rangeWords(G, low, high)
+	} else {
+		Candidates = IS.allWords()
+	}
+	Results := make([]string, 0)
+	Values := make([]interface{}, 0)
+	Spans := make([][][]int, 0)
+	for _, x := range Candidates {
+		if !G.step(1) {
+			break
+		}
+		Matches := Regexp.FindAllIndex(IS.Words[x], -1)
+		if Matches == nil {
+			continue
+		}
+		Results = append(Results, IS.Results[x])
+		Values = append(Values, IS.Values[x])
+		Spans = append(Spans, Matches)
+		if len(Results) == ResultsLimit {
+			break
+		}
+	}
+	return Results, Values, Spans, G.err
+}
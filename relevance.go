@@ -0,0 +1,118 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"math"
+)
+
+// Relevance configures the relevance scores of RelevanceSorter and MultiRelevanceSorter,
+// a BM25 style score where rarer terms score higher and matches in shorter words score higher,
+// scaled up for matches near the start of the word and for matches covering more of the word
+type Relevance struct {
+	K1             float64                   // K1 controls how quickly repeated matches stop adding to the score (BM25 k1)
+	B              float64                   // B controls how much longer words are penalized, from 0 (none) to 1 (BM25 b)
+	PositionWeight float64                   // PositionWeight scales up matches near the start of the word
+	CoverageWeight float64                   // CoverageWeight scales up matches covering more of the word
+	Boost          func(interface{}) float64 // Boost produces a static score added to the relevance from the Value of the word. May be nil
+}
+
+// DefaultRelevance is the usual BM25 parameters, with matches near the start of the word
+// and matches covering all of the word scoring up to 50% higher each
+var DefaultRelevance = Relevance{K1: 1.2, B: 0.75, PositionWeight: 0.5, CoverageWeight: 0.5}
+
+// averageLength returns the average length of the words, in bytes
+func (IS *InvertedSuffix) averageLength() float64 {
+	if len(IS.Words) == 0 {
+		return 1
+	}
+	if !IS.RuneAligned {
+		// Every byte starts a suffix
+		return float64(len(IS.WordIndex)) / float64(len(IS.Words))
+	}
+	Total := 0
+	for _, Word := range IS.Words {
+		Total += len(Word)
+	}
+	return float64(Total) / float64(len(IS.Words))
+}
+
+// idf returns the inverse document frequency of the converted query,
+// estimating its document frequency from the size of its suffix range
+func (IS *InvertedSuffix) idf(Query []byte) float64 {
+	low, high := IS.Search(Query)
+	N := float64(len(IS.Words))
+	df := math.Min(float64(high-low), N)
+	return math.Log(1 + (N-df+0.5)/(df+0.5))
+}
+
+// term returns the score of one match of a term of length n and inverse document frequency idf,
+// at Index in a word of length Length
+func (R Relevance) term(idf float64, n, Length, Index int, Average float64) float64 {
+	// Each match is scored on its own, so the term frequency is 1
+	Norm := R.K1 * (1 - R.B + R.B*float64(Length)/Average)
+	Score := idf * (R.K1 + 1) / (1 + Norm)
+	if Length > 0 {
+		Score *= 1 + R.PositionWeight*(1-float64(Index)/float64(Length)) + R.CoverageWeight*float64(n)/float64(Length)
+	}
+	return Score
+}
+
+// RelevanceSorter returns a sorter for SortedQuery (and the other sorted queries taking the same sorter)
+// scoring the relevance of each match of the query
+// Input:
+//     Word: The substring being searched for, as given to SortedQuery
+//     R: The relevance parameters, e.g. DefaultRelevance
+func (IS *InvertedSuffix) RelevanceSorter(Word string, R Relevance) func(string, interface{}, int, int) float64 {
+	Query := IS.Converter(Word)
+	idf := IS.idf(Query)
+	Average := IS.averageLength()
+	n := len(Query)
+	return func(s string, v interface{}, l int, i int) float64 {
+		Score := R.term(idf, n, l, i, Average)
+		if R.Boost != nil {
+			Score += R.Boost(v)
+		}
+		return Score
+	}
+}
+
+// MultiRelevanceSorter returns a sorter for SortedMultiQuery scoring the relevance of each result,
+// as the sum of the relevance of each term, so rarer terms count for more
+// Input:
+//     Words: The substrings being searched for, as given to SortedMultiQuery
+//     R: The relevance parameters, e.g. DefaultRelevance
+func (IS *InvertedSuffix) MultiRelevanceSorter(Words string, R Relevance) func(string, interface{}, int, []int) float64 {
+	Terms := IS.Terms(Words)
+	idfs := make([]float64, len(Terms))
+	for t, Term := range Terms {
+		idfs[t] = IS.idf(Term)
+	}
+	Average := IS.averageLength()
+	return func(s string, v interface{}, l int, Positions []int) float64 {
+		Score := 0.0
+		for t, Term := range Terms {
+			Score += R.term(idfs[t], len(Term), l, Positions[t], Average)
+		}
+		if R.Boost != nil {
+			Score += R.Boost(v)
+		}
+		return Score
+	}
+}
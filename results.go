@@ -0,0 +1,120 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import "sort"
+
+// A scored result, kept by topResults
+type scoredResult struct {
+	ID     int
+	Aux    int
+	Result string
+	Value  interface{}
+	Score  float64
+}
+
+// topResults keeps the best scoring result for each ID, sorted by score (largest first)
+// With a limit, only the top Limit results are kept as they are added.
+// Aux is extra per-result data, e.g. which alias or field matched
+type topResults struct {
+	Limit int
+	Top   []scoredResult
+	Best  map[int]scoredResult
+}
+
+// newTopResults creates a topResults with the given limit. Set to -1 for no limit
+func newTopResults(Limit int) *topResults {
+	return &topResults{Limit: Limit, Best: make(map[int]scoredResult)}
+}
+
+// add records a result, replacing any lower scoring result with the same ID
+func (TR *topResults) add(ID, Aux int, Result string, Value interface{}, Score float64) {
+	if ps, ok := TR.Best[ID]; ok {
+		if ps.Score >= Score {
+			return
+		}
+		if TR.Limit >= 0 {
+			for i, r := range TR.Top {
+				if r.ID == ID {
+					TR.Top = append(TR.Top[:i], TR.Top[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	r := scoredResult{ID, Aux, Result, Value, Score}
+	TR.Best[ID] = r
+	if TR.Limit < 0 {
+		return
+	}
+	a := len(TR.Top)
+	i := 0
+	j := a
+	for i < j {
+		h := (i + j) >> 1
+		if TR.Top[h].Score > Score {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	if a == TR.Limit {
+		if i == a {
+			return
+		}
+		copy(TR.Top[i+1:], TR.Top[i:a-1])
+		TR.Top[i] = r
+		return
+	}
+	TR.Top = append(TR.Top, scoredResult{})
+	copy(TR.Top[i+1:], TR.Top[i:])
+	TR.Top[i] = r
+}
+
+// results returns the kept results, sorted by score (largest first)
+func (TR *topResults) results() []scoredResult {
+	if TR.Limit >= 0 {
+		return TR.Top
+	}
+	Top := make([]scoredResult, 0, len(TR.Best))
+	for _, r := range TR.Best {
+		Top = append(Top, r)
+	}
+	sort.Slice(Top, func(i, j int) bool {
+		if Top[i].Score != Top[j].Score {
+			return Top[i].Score > Top[j].Score
+		}
+		return Top[i].ID < Top[j].ID
+	})
+	return Top
+}
+
+// split returns the kept results, values and scores as separate arrays
+func (TR *topResults) split() ([]string, []interface{}, []float64) {
+	Top := TR.results()
+	Results := make([]string, len(Top))
+	Values := make([]interface{}, len(Top))
+	Scores := make([]float64, len(Top))
+	for i, r := range Top {
+		Results[i] = r.Result
+		Values[i] = r.Value
+		Scores[i] = r.Score
+	}
+	return Results, Values, Scores
+}
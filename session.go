@@ -0,0 +1,150 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"context"
+	"unicode/utf8"
+)
+
+// Session is an autocomplete session over an InvertedSuffix, for a query typed one character at a time
+// It remembers the suffix range of every prefix of the converted query, so typing a character
+// only narrows the previous range by the new bytes, and a backspace just pops back to an earlier range.
+// Insert invalidates sessions
+type Session struct {
+	IS        *InvertedSuffix
+	Text      string   // Text is the query typed so far
+	Converted []byte   // Converted is IS.Converter(Text)
+	ranges    [][2]int // ranges[a] is the suffix range (low/high) of Converted[:a]
+}
+
+// NewSession starts an autocomplete session with an empty query
+func (IS *InvertedSuffix) NewSession() *Session {
+	return &Session{IS: IS, Converted: []byte{}, ranges: [][2]int{{0, len(IS.WordIndex)}}}
+}
+
+// Type appends s to the query
+func (S *Session) Type(s string) {
+	S.SetText(S.Text + s)
+}
+
+// Backspace removes the last character of the query, if any
+func (S *Session) Backspace() {
+	_, Size := utf8.DecodeLastRuneInString(S.Text)
+	S.SetText(S.Text[:len(S.Text)-Size])
+}
+
+// SetText replaces the query with Text
+// Only the converted bytes after those shared with the previous query are searched
+func (S *Session) SetText(Text string) {
+	Converted := S.IS.Converter(Text)
+	a := 0
+	for a < len(Converted) && a < len(S.Converted) && Converted[a] == S.Converted[a] {
+		a++
+	}
+	S.ranges = S.ranges[:a+1]
+	for ; a < len(Converted); a++ {
+		low, high := S.ranges[a][0], S.ranges[a][1]
+		if low < high {
+			low, high = S.IS.Narrow(low, high, Converted[:a+1], a)
+		}
+		S.ranges = append(S.ranges, [2]int{low, high})
+	}
+	S.Text = Text
+	S.Converted = Converted
+}
+
+// Range returns the boundaries (low/high) of sorted suffixes which have the query as a prefix, as Search
+func (S *Session) Range() (int, int) {
+	Range := S.ranges[len(S.Converted)]
+	return Range[0], Range[1]
+}
+
+// Query returns the strings which contain the query, and their stored values unsorted, as InvertedSuffix.Query
+// Input:
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+func (S *Session) Query(ResultsLimit int) ([]string, []interface{}) {
+	Results, Values, _ := S.QueryContext(context.Background(), ResultsLimit)
+	return Results, Values
+}
+
+// QueryContext is like Query, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (S *Session) QueryContext(ctx context.Context, ResultsLimit int) ([]string, []interface{}, error) {
+	IS := S.IS
+	if ResultsLimit == 0 {
+		return []string{}, []interface{}{}, nil
+	}
+	if ResultsLimit < 0 {
+		ResultsLimit = 0
+	}
+	Results := make([]string, 0, ResultsLimit)
+	Values := make([]interface{}, 0, ResultsLimit)
+	G := newQueryGuard(ctx)
+	low, high := S.Range()
+	a := 0
+	used := make(map[int]bool, 0)
+	for k := low; k < high; k++ {
+		if !G.step(1) {
+			break
+		}
+		x := IS.WordIndex[k]
+		if _, ok := used[x]; ok {
+			continue
+		}
+		used[x] = true
+		Results = append(Results, IS.Results[x])
+		Values = append(Values, IS.Values[x])
+		a++
+		if a == ResultsLimit {
+			break
+		}
+	}
+	return Results, Values, G.err
+}
+
+// SortedQuery returns the strings which contain the query sorted, as InvertedSuffix.SortedQuery
+// Input:
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+//     Sorter: Takes (Result, Value, Length, Index (where Query begins in Result))
+//         (string, interface{}, int, int) and produces a value (float64) to sort by (largest first).
+func (S *Session) SortedQuery(ResultsLimit int, Sorter func(string, interface{}, int, int) float64) ([]string, []interface{}, []float64) {
+	Results, Values, Scores, _ := S.SortedQueryContext(context.Background(), ResultsLimit, Sorter)
+	return Results, Values, Scores
+}
+
+// SortedQueryContext is like SortedQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (S *Session) SortedQueryContext(ctx context.Context, ResultsLimit int, Sorter func(string, interface{}, int, int) float64) ([]string, []interface{}, []float64, error) {
+	IS := S.IS
+	G := newQueryGuard(ctx)
+	low, high := S.Range()
+	Top := newTopResults(ResultsLimit)
+	for k := low; k < high; k++ {
+		if !G.step(1) {
+			break
+		}
+		x := IS.WordIndex[k]
+		w := IS.Results[x]
+		v := IS.Values[x]
+		Top.add(x, 0, w, v, Sorter(w, v, len(IS.Words[x]), IS.SuffixIndex[k]))
+	}
+	Results, Values, Scores := Top.split()
+	return Results, Values, Scores, G.err
+}
@@ -0,0 +1,110 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"context"
+	"sort"
+)
+
+// Suggest returns up to n whole words of the dictionary which the query was most likely meant to be,
+// e.g. for a "did you mean" prompt when a query returns nothing.
+// Suggestions are the words equal to the query or within 1 error correction of it,
+// then within 2 corrections if there are fewer than n of those, ranked by their distance
+// (number of corrections), then by frequency (largest first), then by order of insertion.
+// Returns the suggestions, their stored values, and their distances
+// Input:
+//     Word: The misspelled word
+//     n: The maximum number of suggestions. Set to -1 for no limit (always searches 2 corrections away)
+//     ErrorCorrection: Takes (Query) ([]byte) and produces every correction ([][]byte) one error away
+//         e.g. func(b []byte) [][]byte { return ferret.ErrorCorrect(b, ferret.LowercaseLetters) }
+//     Frequency: Takes the Value of a word (interface{}) and produces its frequency (float64). May be nil
+func (IS *InvertedSuffix) Suggest(Word string, n int, ErrorCorrection func([]byte) [][]byte, Frequency func(interface{}) float64) ([]string, []interface{}, []int) {
+	Results, Values, Dists, _ := IS.SuggestContext(context.Background(), Word, n, ErrorCorrection, Frequency)
+	return Results, Values, Dists
+}
+
+// SuggestContext is like Suggest, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the suggestions found so far and why it stopped
+func (IS *InvertedSuffix) SuggestContext(ctx context.Context, Word string, n int, ErrorCorrection func([]byte) [][]byte, Frequency func(interface{}) float64) ([]string, []interface{}, []int, error) {
+	Query := IS.Converter(Word)
+	G := newQueryGuard(ctx)
+	Distances := make(map[int]int, 0)
+	// lookup records the words equal to Candidate at distance d
+	lookup := func(Candidate []byte, d int) {
+		IS.anchoredScan(G, Candidate, ExactMode, func(k int) bool {
+			x := IS.WordIndex[k]
+			if _, ok := Distances[x]; !ok {
+				Distances[x] = d
+			}
+			return true
+		})
+	}
+	lookup(Query, 0)
+	Seen := map[string]bool{string(Query): true}
+	Candidates := [][]byte{Query}
+Search:
+	for d := 1; d <= 2 && (n < 0 || len(Distances) < n); d++ {
+		Next := make([][]byte, 0)
+		for _, Candidate := range Candidates {
+			for _, Correction := range ErrorCorrection(Candidate) {
+				if Seen[string(Correction)] {
+					continue
+				}
+				if !G.step(1) {
+					break Search
+				}
+				Seen[string(Correction)] = true
+				lookup(Correction, d)
+				Next = append(Next, Correction)
+			}
+		}
+		Candidates = Next
+	}
+	Suggestions := make([]int, 0, len(Distances))
+	Frequencies := make(map[int]float64, len(Distances))
+	for x := range Distances {
+		Suggestions = append(Suggestions, x)
+		if Frequency != nil {
+			Frequencies[x] = Frequency(IS.Values[x])
+		}
+	}
+	sort.Slice(Suggestions, func(i, j int) bool {
+		x, y := Suggestions[i], Suggestions[j]
+		if Distances[x] != Distances[y] {
+			return Distances[x] < Distances[y]
+		}
+		if Frequencies[x] != Frequencies[y] {
+			return Frequencies[x] > Frequencies[y]
+		}
+		return x < y
+	})
+	if n >= 0 && len(Suggestions) > n {
+		Suggestions = Suggestions[:n]
+	}
+	Results := make([]string, len(Suggestions))
+	Values := make([]interface{}, len(Suggestions))
+	Dists := make([]int, len(Suggestions))
+	for i, x := range Suggestions {
+		Results[i] = IS.Results[x]
+		Values[i] = IS.Values[x]
+		Dists[i] = Distances[x]
+	}
+	return Results, Values, Dists, G.err
+}
@@ -0,0 +1,177 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+)
+
+// CyrillicToLatin maps Cyrillic letters to Latin using BGN/PCGN romanization without diacritics
+// Covers Russian, Ukrainian, Belarusian, Serbian and Macedonian letters
+var CyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "u",
+	'ђ': "dj", 'ј': "j", 'љ': "lj", 'њ': "nj", 'ћ': "c", 'џ': "dz",
+	'ѓ': "gj", 'ѕ': "dz", 'ќ': "kj",
+}
+
+// GreekToLatin maps Greek letters to Latin using ELOT 743 letter-by-letter transcription
+var GreekToLatin = map[rune]string{
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o",
+	'ϊ': "i", 'ϋ': "y", 'ΐ': "i", 'ΰ': "y",
+}
+
+// HebrewToLatin maps Hebrew letters and vowel points to Latin using a simplified academic transcription
+// Cantillation and other marks without a sound value are dropped
+var HebrewToLatin = map[rune]string{
+	'א': "", 'ב': "b", 'ג': "g", 'ד': "d", 'ה': "h", 'ו': "v", 'ז': "z",
+	'ח': "ch", 'ט': "t", 'י': "y", 'כ': "k", 'ך': "k", 'ל': "l", 'מ': "m",
+	'ם': "m", 'נ': "n", 'ן': "n", 'ס': "s", 'ע': "", 'פ': "p", 'ף': "f",
+	'צ': "ts", 'ץ': "ts", 'ק': "k", 'ר': "r", 'ש': "sh", 'ת': "t",
+	'ְ': "", 'ֱ': "e", 'ֲ': "a", 'ֳ': "o", 'ִ': "i",
+	'ֵ': "e", 'ֶ': "e", 'ַ': "a", 'ָ': "a", 'ֹ': "o",
+	'ֺ': "o", 'ֻ': "u", 'ּ': "", 'ֽ': "", 'ֿ': "",
+	'ׁ': "", 'ׂ': "", 'ׇ': "o", '׳': "", '״': "",
+}
+
+// ArabicToLatin maps Arabic (and common Persian) letters, harakat and digits to Latin
+// using a simplified DIN 31635 transcription without diacritics
+var ArabicToLatin = map[rune]string{
+	'ا': "a", 'أ': "a", 'إ': "i", 'آ': "a", 'ٱ': "a", 'ب': "b", 'ت': "t",
+	'ث': "th", 'ج': "j", 'ح': "h", 'خ': "kh", 'د': "d", 'ذ': "dh", 'ر': "r",
+	'ز': "z", 'س': "s", 'ش': "sh", 'ص': "s", 'ض': "d", 'ط': "t", 'ظ': "z",
+	'ع': "", 'غ': "gh", 'ف': "f", 'ق': "q", 'ك': "k", 'ل': "l", 'م': "m",
+	'ن': "n", 'ه': "h", 'و': "w", 'ي': "y", 'ى': "a", 'ة': "a", 'ء': "",
+	'ؤ': "", 'ئ': "", 'پ': "p", 'چ': "ch", 'ژ': "zh", 'گ': "g", 'ک': "k",
+	'ی': "y", 'ـ': "",
+	'ً': "an", 'ٌ': "un", 'ٍ': "in", 'َ': "a",
+	'ُ': "u", 'ِ': "i", 'ّ': "", 'ْ': "", 'ٰ': "a",
+	'٠': "0", '١': "1", '٢': "2", '٣': "3", '٤': "4",
+	'٥': "5", '٦': "6", '٧': "7", '٨': "8", '٩': "9",
+	'۰': "0", '۱': "1", '۲': "2", '۳': "3", '۴': "4",
+	'۵': "5", '۶': "6", '۷': "7", '۸': "8", '۹': "9",
+}
+
+// Tables only list lowercase letters. Add the capitalized forms here
+func init() {
+	for _, Table := range []map[rune]string{CyrillicToLatin, GreekToLatin} {
+		for r, s := range Table {
+			u := unicode.ToUpper(r)
+			if u == r {
+				continue
+			}
+			if _, ok := Table[u]; !ok && s != "" {
+				Table[u] = strings.ToUpper(s[:1]) + s[1:]
+			} else if !ok {
+				Table[u] = ""
+			}
+		}
+	}
+}
+
+// Transliterate converts s to Latin script using the given tables, in order of precedence
+// Runes not found in any table are converted using the UnicodeToASCII table
+func Transliterate(s string, Tables ...map[rune]string) string {
+	Result := make([]byte, 0, len(s))
+	for _, r := range s {
+		found := false
+		for _, Table := range Tables {
+			if t, ok := Table[r]; ok {
+				Result = append(Result, t...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			Result = append(Result, string(ToASCII(r))...)
+		}
+	}
+	return string(Result)
+}
+
+// CyrillicToLowerASCII transliterates Cyrillic to lowercase ASCII using the CyrillicToLatin table
+func CyrillicToLowerASCII(s string) []byte {
+	return UnicodeToLowerASCII(Transliterate(s, CyrillicToLatin))
+}
+
+// GreekToLowerASCII transliterates Greek to lowercase ASCII using the GreekToLatin table
+func GreekToLowerASCII(s string) []byte {
+	return UnicodeToLowerASCII(Transliterate(s, GreekToLatin))
+}
+
+// HebrewToLowerASCII transliterates Hebrew to lowercase ASCII using the HebrewToLatin table
+func HebrewToLowerASCII(s string) []byte {
+	return UnicodeToLowerASCII(Transliterate(s, HebrewToLatin))
+}
+
+// ArabicToLowerASCII transliterates Arabic to lowercase ASCII using the ArabicToLatin table
+func ArabicToLowerASCII(s string) []byte {
+	return UnicodeToLowerASCII(Transliterate(s, ArabicToLatin))
+}
+
+// TransliterateToLowerASCII transliterates Cyrillic, Greek, Hebrew and Arabic to lowercase ASCII
+func TransliterateToLowerASCII(s string) []byte {
+	return UnicodeToLowerASCII(Transliterate(s, CyrillicToLatin, GreekToLatin, HebrewToLatin, ArabicToLatin))
+}
+
+// FormSeparator separates the native and transliterated forms of a word
+// indexed by NewTransliterated, so that a query can't match across both forms
+const FormSeparator = 0
+
+// transliteratedForm joins the native and transliterated forms of Word,
+// or returns just the native form if the two are the same
+func transliteratedForm(Word string, Converter, Transliterator func(string) []byte) []byte {
+	Native := Converter(Word)
+	Latin := Transliterator(Word)
+	if bytes.Equal(Native, Latin) {
+		return Native
+	}
+	Form := make([]byte, 0, len(Native)+1+len(Latin))
+	Form = append(Form, Native...)
+	Form = append(Form, FormSeparator)
+	return append(Form, Latin...)
+}
+
+// NewTransliterated creates an inverted suffix like New, but indexes both the native (Converter) and
+// transliterated (Transliterator) forms of each word, both mapping to the same result.
+// Queries are converted using Converter, so a native or a transliterated query finds the same results.
+// Lengths and indices passed to sorters are relative to the combined form
+func NewTransliterated(Words, Results []string, Data []interface{}, Converter, Transliterator func(string) []byte) *InvertedSuffix {
+	IS := New(Words, Results, Data, func(s string) []byte {
+		return transliteratedForm(s, Converter, Transliterator)
+	})
+	IS.Converter = Converter
+	return IS
+}
+
+// InsertTransliterated adds a word to the dictionary like Insert, indexing both
+// its native and transliterated forms. See NewTransliterated
+func (IS *InvertedSuffix) InsertTransliterated(Word, Result string, Data interface{}, Transliterator func(string) []byte) {
+	IS.insert(transliteratedForm(Word, IS.Converter, Transliterator), Word, Result, Data)
+}
@@ -0,0 +1,179 @@
+// Copyright 2013 Mark Canning
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//
+// Author: Mark Canning
+// Developed at: Tamber, Inc. (http://www.tamber.com/).
+
+package ferret
+
+import (
+	"context"
+)
+
+// Wildcard units of a parsed pattern. Other units are literal bytes/runes
+const (
+	anyOne  = -1 // '?'
+	anyMany = -2 // '*'
+)
+
+// parseWildcard splits a wildcard pattern into units, converting each run of literal characters.
+// Also returns the longest converted literal, to narrow the candidates with
+func (IS *InvertedSuffix) parseWildcard(Pattern string) ([]int, []byte) {
+	Units := make([]int, 0, len(Pattern))
+	var Longest []byte
+	Literal := make([]byte, 0)
+	flush := func() {
+		if len(Literal) == 0 {
+			return
+		}
+		Query := IS.Converter(string(Literal))
+		if len(Query) > len(Longest) {
+			Longest = Query
+		}
+		Units = append(Units, IS.units(Query)...)
+		Literal = Literal[:0]
+	}
+	for i := 0; i < len(Pattern); i++ {
+		c := Pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(Pattern):
+			i++
+			Literal = append(Literal, Pattern[i])
+		case c == '?':
+			flush()
+			Units = append(Units, anyOne)
+		case c == '*':
+			flush()
+			Units = append(Units, anyMany)
+		default:
+			Literal = append(Literal, c)
+		}
+	}
+	flush()
+	return Units, Longest
+}
+
+// units splits a converted word into bytes, or runes if IS is rune aligned
+func (IS *InvertedSuffix) units(Word []byte) []int {
+	if IS.RuneAligned {
+		Units := make([]int, 0, len(Word))
+		for _, r := range string(Word) {
+			Units = append(Units, int(r))
+		}
+		return Units
+	}
+	Units := make([]int, len(Word))
+	for i, c := range Word {
+		Units[i] = int(c)
+	}
+	return Units
+}
+
+// matchWildcard returns whether the pattern matches some substring of the word
+func matchWildcard(Pattern, Word []int) bool {
+	// Match '*' + Pattern + '*' against the whole word, backtracking to the last '*'
+	Units := make([]int, 0, len(Pattern)+2)
+	Units = append(Units, anyMany)
+	Units = append(Units, Pattern...)
+	Units = append(Units, anyMany)
+	p, w := 0, 0
+	star, mark := -1, 0
+	for w < len(Word) {
+		switch {
+		case p < len(Units) && (Units[p] == anyOne || Units[p] == Word[w]):
+			p++
+			w++
+		case p < len(Units) && Units[p] == anyMany:
+			star = p
+			mark = w
+			p++
+		case star >= 0:
+			p = star + 1
+			mark++
+			w = mark
+		default:
+			return false
+		}
+	}
+	for p < len(Units) && Units[p] == anyMany {
+		p++
+	}
+	return p == len(Units)
+}
+
+// WildcardQuery returns the strings which contain the wildcard pattern, and their stored values unsorted
+// '?' matches any single byte (or rune, for NewRuneAligned), and '*' matches any run of them.
+// Use '\' to escape a '?', '*' or '\'. Literal runs of the pattern are converted with the Converter.
+// Candidates are narrowed with the longest literal run, then checked against the whole pattern
+// Input:
+//     Pattern: The wildcard pattern to search for, e.g. "b?t*les"
+//     ResultsLimit: Limit the results to some number of values. Set to -1 for no limit
+func (IS *InvertedSuffix) WildcardQuery(Pattern string, ResultsLimit int) ([]string, []interface{}) {
+	Results, Values, _ := IS.WildcardQueryContext(context.Background(), Pattern, ResultsLimit)
+	return Results, Values
+}
+
+// WildcardQueryContext is like WildcardQuery, but stops early when ctx is done or its work budget
+// (see WithWorkBudget) is exceeded, returning the results found so far and why it stopped
+func (IS *InvertedSuffix) WildcardQueryContext(ctx context.Context, Pattern string, ResultsLimit int) ([]string, []interface{}, error) {
+	Units, Longest := IS.parseWildcard(Pattern)
+	if ResultsLimit == 0 {
+		return []string{}, []interface{}{}, nil
+	}
+	if ResultsLimit < 0 {
+		ResultsLimit = 0
+	}
+	Results := make([]string, 0, ResultsLimit)
+	Values := make([]interface{}, 0, ResultsLimit)
+	G := newQueryGuard(ctx)
+	a := 0
+	check := func(x int) bool {
+		if !matchWildcard(Units, IS.units(IS.Words[x])) {
+			return false
+		}
+		Results = append(Results, IS.Results[x])
+		Values = append(Values, IS.Values[x])
+		a++
+		return a == ResultsLimit
+	}
+	if len(Longest) == 0 {
+		// No literal to narrow with, so check every word
+		for x := range IS.Words {
+			if !G.step(1) {
+				return Results, Values, G.err
+			}
+			if check(x) {
+				break
+			}
+		}
+		return Results, Values, nil
+	}
+	low, high := IS.Search(Longest)
+	used := make(map[int]bool, 0)
+	for k := low; k < high; k++ {
+		if !G.step(1) {
+			return Results, Values, G.err
+		}
+		x := IS.WordIndex[k]
+		if _, ok := used[x]; ok {
+			continue
+		}
+		used[x] = true
+		if check(x) {
+			break
+		}
+	}
+	return Results, Values, nil
+}